@@ -1,30 +1,22 @@
 package crawler
 
 import (
-	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/terencechow/crawl/parser"
-	"golang.org/x/net/html"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"sync"
 	"time"
 )
 
-// Enums for visiting / visited status of a url
-type VisitStatus int
-
-const (
-	VISITING VisitStatus = 1
-	VISITED  VisitStatus = 2
-)
-
-// data structure for tracking visiting / visited status
+// data structure for tracking the number of attempts made to fetch each url
 type VisitState struct {
-	urlmap map[string]VisitStatus
+	attempts map[string]int
 	sync.Mutex
 }
 
@@ -32,6 +24,23 @@ type VisitState struct {
 type Node struct {
 	URL   string
 	Links map[string]*Node
+
+	// element and attribute the url was found in on the parent page, ie a[href]. Empty for the root
+	Source string
+
+	// reason the url was not crawled, empty if it was
+	Skipped string
+
+	// NoIndex is true for pages asking not to be indexed with <meta name="robots"> or X-Robots-Tag
+	NoIndex bool
+
+	// Status is the HTTP status code of the last response to the url, 0 if it was not fetched
+	Status int
+}
+
+// IsEmbed returns true if the parent page embeds the url, ie with an iframe, rather than links to it
+func (self *Node) IsEmbed() bool {
+	return EMBED_SOURCES[self.Source]
 }
 
 // data structure for tracking the full Sitemap and a "Parentmap"
@@ -46,6 +55,9 @@ type Info struct {
 // Constant to indicate a node in the Parentmap has no parent (ie is the root url)
 const ROOT = "ROOT"
 
+// Constant url of the synthetic root node listing the seeds when crawling several seeds
+const SEEDS = "SEEDS"
+
 // GetNodeFromSitemap returns a specific node in the sitemap given a url
 func (self *Info) GetNodeFromSitemap(currentURL string) *Node {
 	path := []string{}
@@ -73,155 +85,487 @@ func (self *Info) GetNodeFromSitemap(currentURL string) *Node {
 }
 
 // data structure tracking every link that *WILL* be visited
-// This is separate from what's visited / visiting because the locks won't block each other.
-// toVisit is for tracking every link that needs to be visited
-// in order to compare with what we have visited and figure out when crawling is complete
+// toVisit is checked before adding a link to the frontier so each link is crawled once
 type ToVisit struct {
 	urlmap map[string]bool
 	sync.Mutex
 }
 
-/* Global variables */
-// instances for above data structures
-var info = &Info{
-	Sitemap:   &Node{},
-	Parentmap: make(map[string]string),
+// DEFAULT_WORKERS is the number of goroutines spawned when no WithWorkers option is given
+const DEFAULT_WORKERS = 4
+
+// Crawler crawls a website and builds a Sitemap.
+// Each Crawler has its own frontier, visit state and Sitemap so several crawls can run in the same process
+type Crawler struct {
+	numWorkers  int
+	maxDepth    int
+	maxPages    int
+	maxDuration time.Duration
+	userAgent   string
+	fetcher     Fetcher
+
+	// robots.txt rules per host, fetched once per crawl
+	ignoreRobots bool
+	robots       map[string]*robotsEntry
+	robotsLock   sync.Mutex
+
+	// requests per second & burst per host, shared by every worker
+	rate    float64
+	burst   int
+	limiter *rateLimiter
+
+	retryPolicy RetryPolicy
+
+	// collapse pages onto the url of their <link rel="canonical">
+	canonical bool
+
+	// honor rel=nofollow, <meta name="robots"> and X-Robots-Tag
+	robotsMeta bool
+
+	// LinkExtractor per content type, the Scope links must be in to be crawled and the Normalizer of their urls
+	extractors Extractors
+	scope      Scope
+	normalizer parser.Normalizer
+
+	// include & exclude rules of the urls crawled, excluded urls are listed as out of scope with listExcluded
+	rules        *URLRules
+	listExcluded bool
+
+	// called with every page once crawled, one call at a time
+	onPage     func(Page)
+	onPageLock sync.Mutex
+
+	// context of the current crawl, requests are aborted once it is done
+	ctx context.Context
+
+	info       *Info
+	toVisit    *ToVisit
+	visitState *VisitState
+
+	// urls waiting to be crawled, guarded by frontierLock so Stop can be called at any time
+	frontier     *frontier
+	frontierLock sync.Mutex
+
+	// tracks the workers so the state above is not reset while one is still running
+	workers sync.WaitGroup
+
+	// only one crawl at a time may use the state above
+	running sync.Mutex
+}
+
+// Errors returned by CrawlContext when the crawl ended before every link was crawled
+var (
+	ErrStopped     = errors.New("crawl stopped")
+	ErrMaxPages    = errors.New("max pages budget reached")
+	ErrMaxDuration = errors.New("max duration budget reached")
+)
+
+// errRedirected is returned by crawl for pages replaced in the Sitemap by their meta refresh or canonical url
+var errRedirected = errors.New("redirected")
+
+// Option configures a Crawler
+type Option func(*Crawler)
+
+// WithWorkers sets the number of goroutines that crawl concurrently
+func WithWorkers(numWorkers int) Option {
+	return func(self *Crawler) {
+		self.numWorkers = numWorkers
+	}
+}
+
+// WithMaxDepth limits how many links are followed from the root url.
+// Links beyond the limit are recorded in the Sitemap as leaves but not fetched. 0 means no limit
+func WithMaxDepth(maxDepth int) Option {
+	return func(self *Crawler) {
+		self.maxDepth = maxDepth
+	}
+}
+
+// WithMaxPages stops the crawl once maxPages urls were fetched. 0 means no limit
+func WithMaxPages(maxPages int) Option {
+	return func(self *Crawler) {
+		self.maxPages = maxPages
+	}
 }
-var toVisit = &ToVisit{urlmap: make(map[string]bool)}
-var visitState = &VisitState{urlmap: make(map[string]VisitStatus)}
 
-// queue channel
-var queue = make(chan string)
+// WithMaxDuration stops the crawl once it has been running for maxDuration. 0 means no limit
+func WithMaxDuration(maxDuration time.Duration) Option {
+	return func(self *Crawler) {
+		self.maxDuration = maxDuration
+	}
+}
 
-// quit channel
-var quit = make(chan bool)
+// WithFetcher replaces the HTTPFetcher used to fetch pages and robots.txt files.
+// The fetcher is responsible for its own User-Agent header
+func WithFetcher(fetcher Fetcher) Option {
+	return func(self *Crawler) {
+		self.fetcher = fetcher
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests, also used to pick the robots.txt rules that apply
+func WithUserAgent(userAgent string) Option {
+	return func(self *Crawler) {
+		self.userAgent = userAgent
+	}
+}
+
+// WithIgnoreRobots crawls urls even when robots.txt disallows them
+func WithIgnoreRobots(ignoreRobots bool) Option {
+	return func(self *Crawler) {
+		self.ignoreRobots = ignoreRobots
+	}
+}
+
+// WithRateLimit limits the requests sent to each host to rate per second, allowing bursts of up to burst requests.
+// A Crawl-delay in robots.txt tightens the limit. A rate of 0 means no limit other than Crawl-delay
+func WithRateLimit(rate float64, burst int) Option {
+	return func(self *Crawler) {
+		self.rate = rate
+		self.burst = burst
+	}
+}
+
+// WithRetryPolicy sets which failed requests are retried and how long to wait between attempts
+func WithRetryPolicy(retryPolicy RetryPolicy) Option {
+	return func(self *Crawler) {
+		self.retryPolicy = retryPolicy
+	}
+}
+
+// WithExtractor registers a LinkExtractor for pages of mimeType, replacing any existing one
+func WithExtractor(mimeType string, extractor LinkExtractor) Option {
+	return func(self *Crawler) {
+		self.extractors[mimeType] = extractor
+	}
+}
+
+// WithCanonical treats <link rel="canonical"> like a redirect so duplicate urls collapse onto the node of their canonical url
+func WithCanonical(canonical bool) Option {
+	return func(self *Crawler) {
+		self.canonical = canonical
+	}
+}
+
+// WithRobotsMeta honors rel=nofollow, <meta name="robots"> and the X-Robots-Tag header.
+// Nofollow links are recorded in the Sitemap as skipped without being crawled and noindex pages are marked NoIndex
+func WithRobotsMeta(robotsMeta bool) Option {
+	return func(self *Crawler) {
+		self.robotsMeta = robotsMeta
+	}
+}
+
+// WithNormalizer replaces the parser.DefaultNormalizer of the urls found on pages
+func WithNormalizer(normalizer parser.Normalizer) Option {
+	return func(self *Crawler) {
+		self.normalizer = normalizer
+	}
+}
+
+// WithURLRules only crawls urls matching the include and exclude patterns of rules. The root url is always crawled
+func WithURLRules(rules *URLRules) Option {
+	return func(self *Crawler) {
+		self.rules = rules
+	}
+}
+
+// WithListExcluded lists the urls excluded by the URLRules in the Sitemap as skipped with OUT_OF_SCOPE
+func WithListExcluded(listExcluded bool) Option {
+	return func(self *Crawler) {
+		self.listExcluded = listExcluded
+	}
+}
+
+// WithOnPage calls onPage with the record of every url once it is crawled, ie to stream results while crawling.
+// Calls are serialized so onPage doesn't need to be safe for concurrent use, but it slows down the crawl while it runs
+func WithOnPage(onPage func(Page)) Option {
+	return func(self *Crawler) {
+		self.onPage = onPage
+	}
+}
+
+// WithScope replaces the SameHostScope deciding which links are crawled
+func WithScope(scope Scope) Option {
+	return func(self *Crawler) {
+		self.scope = scope
+	}
+}
+
+// New returns a Crawler configured with the given options
+func New(opts ...Option) *Crawler {
+	crawler := &Crawler{
+		numWorkers:  DEFAULT_WORKERS,
+		userAgent:   DEFAULT_USER_AGENT,
+		burst:       1,
+		retryPolicy: DefaultRetryPolicy,
+		extractors:  DefaultExtractors(),
+		scope:       SameHostScope,
+		normalizer:  parser.DefaultNormalizer,
+	}
+	for _, opt := range opts {
+		opt(crawler)
+	}
+	if crawler.fetcher == nil {
+		crawler.fetcher = NewHTTPFetcher(crawler.userAgent)
+	}
+	return crawler
+}
 
 // CreateSiteMap crawls a url, all available links on that page and returns a Sitemap
 // If a link is already fetched it does not fetch that link again
 func CreateSiteMap(rootURL string, numWorkers int) *Node {
+	return New(WithWorkers(numWorkers)).Crawl(rootURL)
+}
 
-	// initialize Sitemap
-	info.Sitemap.URL = rootURL
-	info.Sitemap.Links = make(map[string]*Node)
+// Crawl crawls a url, all available links on that page and returns a Sitemap
+// A Crawler can be reused, every call starts from a fresh state.
+// Calls on the same Crawler are serialized, use separate Crawlers to crawl concurrently
+func (self *Crawler) Crawl(rootURL string) *Node {
+	sitemap, _ := self.CrawlContext(context.Background(), rootURL)
+	return sitemap
+}
 
-	// initialize Parentmap
-	info.Parentmap[rootURL] = ROOT
+// CrawlContext is like Crawl but stops once ctx is cancelled or its deadline passes.
+// In-flight requests are aborted and the partial Sitemap gathered so far is returned along with ctx.Err()
+// When the page or duration budget is reached in-flight requests are finished without further retries and ErrMaxPages / ErrMaxDuration is returned
+func (self *Crawler) CrawlContext(ctx context.Context, rootURL string) (*Node, error) {
+	return self.CrawlSeeds(ctx, []string{rootURL})
+}
 
-	// initialize toVisit
-	toVisit.urlmap[rootURL] = true
+// CrawlSeeds is like CrawlContext starting from several urls, ie landing pages not linked from the homepage.
+// With more than one seed the Sitemap is a synthetic root with the url SEEDS linking to a node per seed
+func (self *Crawler) CrawlSeeds(ctx context.Context, seeds []string) (*Node, error) {
+	self.running.Lock()
+	defer self.running.Unlock()
+
+	self.ctx = ctx
+	rootURLs := []string{}
+	for _, seed := range seeds {
+		if rootURL := self.normalizer.Normalize(seed); !containsString(rootURLs, rootURL) {
+			rootURLs = append(rootURLs, rootURL)
+		}
+	}
 
-	// create goroutines to wait on queue
-	for i := 0; i < numWorkers; i++ {
-		go processQueue(i)
+	// initialize Sitemap & Parentmap
+	if len(rootURLs) == 1 {
+		self.info = &Info{
+			Sitemap:   &Node{URL: rootURLs[0], Links: make(map[string]*Node)},
+			Parentmap: map[string]string{rootURLs[0]: ROOT},
+		}
+	} else {
+		self.info = &Info{
+			Sitemap:   &Node{URL: SEEDS, Links: make(map[string]*Node)},
+			Parentmap: map[string]string{SEEDS: ROOT},
+		}
+		for _, rootURL := range rootURLs {
+			self.info.Sitemap.Links[rootURL] = &Node{URL: rootURL, Links: make(map[string]*Node)}
+			self.info.Parentmap[rootURL] = SEEDS
+		}
 	}
 
+	// initialize toVisit & visitState
+	self.toVisit = &ToVisit{urlmap: make(map[string]bool)}
+	self.visitState = &VisitState{attempts: make(map[string]int)}
+	self.robots = make(map[string]*robotsEntry)
+	self.limiter = newRateLimiter(self.rate, self.burst)
+
+	frontier := newFrontier(ctx, self.maxPages)
+	self.frontierLock.Lock()
+	self.frontier = frontier
+	self.frontierLock.Unlock()
+
 	log.Println("Initializing queue...")
-	// add rootURL to queue to start processing
-	queue <- rootURL
+	// add the seeds to the frontier to start processing
+	self.schedule(0, rootURLs...)
+	frontier.done()
+
+	// create goroutines to crawl the frontier
+	for i := 0; i < self.numWorkers; i++ {
+		self.workers.Add(1)
+		go self.processQueue(i)
+	}
+
+	// cancelling the context closes the frontier so workers return once their fetch is aborted
+	finished := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+			frontier.close(ctx.Err())
+		case <-finished:
+		}
+	}()
+
+	if self.maxDuration > 0 {
+		timer := time.AfterFunc(self.maxDuration, func() {
+			frontier.close(ErrMaxDuration)
+		})
+		defer timer.Stop()
+	}
+
+	// block until all links are crawled or the crawl is cancelled / stopped
+	self.workers.Wait()
+	close(finished)
 
-	// block until all channels visited
-	<-quit
-	log.Println("Done crawling...")
+	// fetches aborted by the context count as done, so check the context first
+	err := ctx.Err()
+	if err == nil {
+		err = frontier.err()
+	}
+	if err != nil {
+		log.Println("Stopped crawling...", err)
+	} else {
+		log.Println("Done crawling...")
+	}
 
-	return info.Sitemap
+	return self.info.Sitemap, err
+}
+
+// Stop stops the current crawl from fetching new urls.
+// Fetches in flight are finished without further retries and CrawlContext returns the partial Sitemap along with ErrStopped.
+// Use a context to bound how long in-flight fetches may take
+func (self *Crawler) Stop() {
+	self.frontierLock.Lock()
+	defer self.frontierLock.Unlock()
+	if self.frontier != nil {
+		self.frontier.close(ErrStopped)
+	}
 }
 
-// check if we have crawled every link
-func terminateIfComplete() {
-	if len(queue) != 0 {
+// Unfetched returns the number of urls the last crawl discovered but did not fetch
+// because it was stopped, cancelled or ran out of budget
+func (self *Crawler) Unfetched() int {
+	self.frontierLock.Lock()
+	defer self.frontierLock.Unlock()
+	if self.frontier == nil {
+		return 0
+	}
+	return self.frontier.waiting()
+}
+
+// schedule adds the links that were never scheduled before to the frontier
+// links deeper than maxDepth are not scheduled and links disallowed by robots.txt are marked as skipped
+func (self *Crawler) schedule(depth int, links ...string) {
+	if self.maxDepth > 0 && depth > self.maxDepth {
 		return
 	}
 
-	// if queue is 0 and the numberToVisit matches numberVisited
-	toVisit.Lock()
-	numToVisit := len(toVisit.urlmap)
-	toVisit.Unlock()
-	numVisited := 0
-	stillVisiting := false
-
-	visitState.Lock()
-	for _, visitState := range visitState.urlmap {
-		if visitState == VISITING {
-			stillVisiting = true
-			break
-		} else if visitState == VISITED {
-			numVisited += 1
+	unscheduled := make([]string, 0, len(links))
+	self.toVisit.Lock()
+	for _, link := range links {
+		if !self.toVisit.urlmap[link] {
+			self.toVisit.urlmap[link] = true
+			unscheduled = append(unscheduled, link)
 		}
 	}
-	visitState.Unlock()
+	self.toVisit.Unlock()
 
-	if !stillVisiting && numToVisit == numVisited {
-		// # of visited sites matches the number toVisit then we are done visiting every site
-		quit <- true
+	// robots.txt may need to be fetched so it is checked without holding the lock
+	newLinks := make([]queued, 0, len(unscheduled))
+	for _, link := range unscheduled {
+		if !self.robotsAllowed(link) {
+			self.skip(link, ROBOTS_DISALLOWED)
+			continue
+		}
+		newLinks = append(newLinks, queued{url: link, depth: depth})
 	}
+	self.frontier.push(newLinks...)
 }
 
-// processQueue blocks on the queue and crawls one url at a time. Links from the url are then added to the queue
-func processQueue(id int) {
-	for currentURL := range queue {
-		// lock to ensure concurrent handlers don't process same url
-		visitState.Lock()
-		if status, urlInMap := visitState.urlmap[currentURL]; urlInMap && (status == VISITING || status == VISITED) {
-			visitState.Unlock()
-			terminateIfComplete()
-			continue
-		} else {
-			visitState.urlmap[currentURL] = VISITING
-			visitState.Unlock()
+// skip marks the node of a url in the Sitemap as skipped with the reason it won't be crawled
+func (self *Crawler) skip(link string, reason string) {
+	self.info.Lock()
+	if node := self.info.GetNodeFromSitemap(link); node != nil {
+		node.Skipped = reason
+	}
+	self.info.Unlock()
+}
+
+// processQueue blocks on the frontier and crawls one url at a time. Links from the url are then added to the frontier
+func (self *Crawler) processQueue(id int) {
+	defer self.workers.Done()
+	for {
+		next, ok := self.frontier.pop()
+		if !ok {
+			return
 		}
+		self.visit(id, next)
 
-		// crawl & get links
-		log.Printf("Goroutine #%v: crawling %s ...\n", id, currentURL)
-		links, err := crawl(currentURL, 1)
-		if err != nil {
-			// for redirects no need to log an error since its not an error and the redirect has been added to queue
-			if redirectRegex := regexp.MustCompile(`^3\d\d$`); redirectRegex.MatchString(err.Error()) {
-				log.Printf("Goroutine #%v: Error crawling %s, %s\n", id, currentURL, err)
-			}
+		// links found on the url are scheduled by now so it can be marked as done
+		self.frontier.done()
+	}
+}
 
-			// for errors we don't add the url back to the queue
-			// because the url may be genuinely inaccessible to us and we don't want a circular dependency
-			visitState.Lock()
-			visitState.urlmap[currentURL] = VISITED
-			visitState.Unlock()
-			terminateIfComplete()
-			continue
+// visit crawls a single url and adds its links to the Sitemap and the frontier
+func (self *Crawler) visit(id int, next queued) {
+	currentURL := next.url
+
+	// crawl & get links
+	log.Printf("Goroutine #%v: crawling %s ...\n", id, currentURL)
+	links, status, err := self.crawl(next)
+	if err != nil {
+		// for errors we don't add the url back to the frontier
+		// because the url may be genuinely inaccessible to us and we don't want a circular dependency
+		// for redirects no need to log an error since its not an error and the redirect has been added to queue
+		if redirectRegex := regexp.MustCompile(`^3\d\d$`); err != errRedirected && !redirectRegex.MatchString(err.Error()) {
+			log.Printf("Goroutine #%v: Error crawling %s, %s\n", id, currentURL, err)
+			self.reportPage(next, status, nil, err)
+		} else {
+			self.reportPage(next, status, nil, nil)
 		}
+		return
+	}
 
-		// grab a specific node in the Sitemap
-		info.Lock()
-		temp := info.GetNodeFromSitemap(currentURL)
-
-		// update links of specific node in Sitemap and update Parentmap for each link
-		// we also add each link to the toVisit map so we must lock that as well
-		toVisit.Lock()
-		for _, link := range links {
-			// if a page links to itself no need to include it in Sitemap
-			if link != currentURL && temp.Links[link] == nil {
-				temp.Links[link] = &Node{URL: link, Links: make(map[string]*Node)}
+	// grab a specific node in the Sitemap
+	self.info.Lock()
+	temp := self.info.GetNodeFromSitemap(currentURL)
+
+	// update links of specific node in Sitemap and update Parentmap for each link
+	urls := make([]string, 0, len(links))
+	found := make([]string, 0, len(links))
+	for _, link := range links {
+		linkURL := link.URL.String()
+
+		// links excluded by the URLRules are left out of the Sitemap unless listed as out of scope
+		skipped := ""
+		if !self.rules.Allowed(link.URL) {
+			if !self.listExcluded {
+				continue
 			}
+			skipped = OUT_OF_SCOPE
+		} else if link.Nofollow && self.robotsMeta {
+			skipped = NOFOLLOW
+		}
 
-			if info.Parentmap[link] == "" {
-				info.Parentmap[link] = currentURL
-			}
-			toVisit.urlmap[link] = true
+		// if a page links to itself no need to include it in Sitemap
+		if linkURL != currentURL && temp.Links[linkURL] == nil {
+			temp.Links[linkURL] = &Node{URL: linkURL, Links: make(map[string]*Node), Source: link.Source(), Skipped: skipped}
+			found = append(found, linkURL)
 		}
-		toVisit.Unlock()
-		info.Unlock()
 
-		go func() {
-			for _, link := range links {
-				queue <- link
-			}
-		}()
+		// skipped links are recorded as leaves, crawled only if followed from another page
+		if skipped != "" {
+			continue
+		}
+		urls = append(urls, linkURL)
 
-		// update the state of this url to visited
-		visitState.Lock()
-		visitState.urlmap[currentURL] = VISITED
-		visitState.Unlock()
+		if self.info.Parentmap[linkURL] == "" {
+			self.info.Parentmap[linkURL] = currentURL
+		}
+	}
+	self.info.Unlock()
+
+	self.schedule(next.depth+1, urls...)
+	self.reportPage(next, status, found, nil)
+}
 
-		terminateIfComplete()
+// containsString returns true if value is in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
 }
 
 // handle relative paths
@@ -232,150 +576,140 @@ func resolveIfRelativePath(currentURL *url.URL, nextURL *url.URL) *url.URL {
 	return nextURL
 }
 
-// GetDomainLinks parses a response body and returns all links within the same domain
-func GetDomainLinks(currentURL *url.URL, body io.ReadCloser) ([]string, error) {
-	links := map[string]bool{}
-	tokenizer := html.NewTokenizer(body)
-	var hrefAttr []byte = []byte("href") // used in bytes.Compare to find href attribute
-	var anchor []byte = []byte("a")      // used in bytes.Compare to find anchor tags
-
-	for {
-		// iterate over tokens
-		tokenType := tokenizer.Next()
-		switch tokenType {
-
-		case html.ErrorToken:
-			err := tokenizer.Err()
-			if err.Error() != "EOF" {
-				return nil, err
-			}
-
-			// done iterating on links, convert links which was a map into an array
-			keys := make([]string, len(links))
-			i := 0
-			for k := range links {
-				keys[i] = k
-				i++
-			}
-			// return the array of links
-			return keys, nil
-
-		case html.StartTagToken:
-			// if token is an anchor tag...
-			if tagName, moreAttr := tokenizer.TagName(); bytes.Equal(tagName, anchor) && moreAttr {
-				var key, val []byte
-
-				// and if it has attributes
-				for moreAttr {
-					key, val, moreAttr = tokenizer.TagAttr()
-					// check if its a href attribute
-					if bytes.Equal(key, hrefAttr) {
-						// grab url from href
-						nextURL, err := url.Parse(string(val))
-						if err != nil {
-							log.Print("Error parsing", err)
-							return nil, err
-						}
-
-						// handle if relative path
-						nextURL = resolveIfRelativePath(currentURL, nextURL)
-
-						// if host matches but scheme missing set the scheme
-						if nextURL.Host == currentURL.Host && nextURL.Scheme == "" {
-							nextURL.Scheme = currentURL.Scheme
-						}
-
-						// get Sitemap only for same domain, scheme & if the link isnt a duplicate
-						if normalizedURL := parser.NormalizeURL(nextURL.String()); !links[normalizedURL] &&
-							nextURL.Host == currentURL.Host && nextURL.Scheme == currentURL.Scheme {
-							links[normalizedURL] = true
-						}
-					}
-				}
-			}
-		}
-	}
-}
-
-// crawl fetches the page and calls GetDomainLinks to return links form the same domain
-func crawl(rawURL string, retryDelay int) ([]string, error) {
-	var client = &http.Client{
-		Timeout: time.Second * 10,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	resp, err := client.Get(rawURL)
+// crawl fetches the page, retrying as the RetryPolicy allows, and returns the links in scope found by its LinkExtractor
+// along with the status code of the response, 0 if there was none
+func (self *Crawler) crawl(next queued) ([]Link, int, error) {
+	rawURL := next.url
+	resp, err := self.fetchWithRetries(rawURL)
 	if err != nil {
 		log.Print("Error with request", err)
-		return nil, err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
-	currentURL, err := url.Parse(rawURL)
+	// record the status before a redirect replaces the node of the page
+	status := resp.StatusCode
+	self.info.Lock()
+	if node := self.info.GetNodeFromSitemap(rawURL); node != nil {
+		node.Status = status
+	}
+	self.info.Unlock()
+
+	// resolve links against the final url in case the Fetcher followed redirects
+	finalURL := resp.URL
+	if finalURL == "" {
+		finalURL = rawURL
+	}
+	currentURL, err := url.Parse(finalURL)
 	if err != nil {
 		log.Print("Error parsing URL", err)
-		return nil, err
+		return nil, status, err
 	}
 
 	if resp.StatusCode > 300 && resp.StatusCode < 400 {
-		// for redirects we don't follow automatically because they may be a different subdomain and we don't use different subdomains in Sitemaps
-		// ie www.blah.com/blog -> blog.blah.com
+		// for redirects we don't follow automatically because they may be out of the Scope
+		// ie www.blah.com/blog -> blog.blah.com with the default SameHostScope
 		nextURL, err := url.Parse(resp.Header.Get("location"))
 		if err != nil {
 			log.Print("Error parsing URL from header location", err)
-			return nil, err
+			return nil, status, err
 		}
-		nextURL = resolveIfRelativePath(currentURL, nextURL)
-
-		if nextRawURL := parser.NormalizeURL(nextURL.String()); nextRawURL != rawURL && currentURL.Host == nextURL.Host {
-
-			// mark the redirect target as something toVisit and add it to the queue
-			toVisit.Lock()
-			toVisit.urlmap[nextRawURL] = true
-			toVisit.Unlock()
-			go func() {
-				queue <- nextRawURL
-			}()
-
-			// update Parentmap & Sitemap, removing the old link and adding the redirect target
-			info.Lock()
-			if parentURL := info.Parentmap[rawURL]; parentURL != "" && info.Parentmap[nextRawURL] == "" {
-				info.Parentmap[nextRawURL] = parentURL
-
-				// update Sitemap to add the final target of redirections
-				parentNode := info.GetNodeFromSitemap(parentURL)
-				parentNode.Links[nextRawURL] = &Node{
-					URL:   nextRawURL,
-					Links: make(map[string]*Node),
-				}
-
-				// remove old link of redirect
-				delete(parentNode.Links, rawURL)
+		self.redirect(next, currentURL, resolveIfRelativePath(currentURL, nextURL), false)
+
+		return nil, status, errors.New(strconv.Itoa(resp.StatusCode))
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		// errors the RetryPolicy doesn't retry or that kept failing
+		return nil, status, fmt.Errorf("%v %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	// pages without a LinkExtractor for their content type have no links to follow
+	var candidates []Link
+	var directives Directives
+	if extractor := self.extractors.For(resp.Header.Get("Content-Type")); extractor != nil {
+		candidates, directives, err = extract(extractor, currentURL, resp.Body)
+		if err != nil {
+			log.Print("Error extracting links", err)
+			return nil, status, err
+		}
+	}
+
+	// meta refresh & canonical urls replace the page in the Sitemap like HTTP redirects
+	links := make([]Link, 0, len(candidates))
+	for _, link := range candidates {
+		switch link.Rel {
+		case REL_REFRESH:
+			if self.redirect(next, currentURL, link.URL, false) {
+				return nil, status, errRedirected
+			}
+		case REL_CANONICAL:
+			if self.canonical && self.redirect(next, currentURL, link.URL, true) {
+				return nil, status, errRedirected
 			}
-			info.Unlock()
+		default:
+			links = append(links, link)
 		}
+	}
 
-		return nil, errors.New(string(resp.StatusCode))
-	} else if resp.StatusCode < 200 || resp.StatusCode > 400 {
-		if resp.StatusCode > 499 && retryDelay <= 16 {
-			// treat 500 errors as the website's problem not ours, retry the crawl with a delay
-			log.Printf("Failed %v on %s. Retrying in %v seconds \n", resp.StatusCode, rawURL, retryDelay)
-			time.Sleep(time.Duration(retryDelay) * time.Second)
-			return crawl(rawURL, retryDelay*2)
-		} else {
-			// 400 errors like bad request, unauthorized, etc
-			// will never succeed even with a backoff so we just return an error
-			return nil, errors.New(resp.Status)
+	if self.robotsMeta {
+		directives = directives.merge(xRobotsTagDirectives(resp.Header["X-Robots-Tag"], self.userAgent))
+		if directives.NoIndex {
+			self.info.Lock()
+			if node := self.info.GetNodeFromSitemap(rawURL); node != nil {
+				node.NoIndex = true
+			}
+			self.info.Unlock()
+		}
+		if directives.NoFollow {
+			for i := range links {
+				links[i].Nofollow = true
+			}
 		}
 	}
 
-	links, err := GetDomainLinks(currentURL, resp.Body)
-	if err != nil {
-		log.Print("Error geting domain links", err)
-		return nil, err
+	return FilterLinks(currentURL, links, self.scope, self.normalizer), status, nil
+}
+
+// redirect replaces the node of the page in the Sitemap with a node for targetURL and adds targetURL to the frontier.
+// Returns false for redirects we don't follow. With collapse, a page whose target is already in the Sitemap is replaced by a leaf referencing it
+func (self *Crawler) redirect(next queued, currentURL *url.URL, targetURL *url.URL, collapse bool) bool {
+	// only redirects within the Scope are part of the Sitemap, redirects within the host are always followed ie http to https
+	rawURL := next.url
+	targetRawURL := self.normalizer.Normalize(targetURL.String())
+	inScope := currentURL.Host == targetURL.Host || self.scope.InScope(currentURL, targetURL)
+	if targetRawURL == rawURL || !inScope || !self.rules.Allowed(targetURL) {
+		return false
+	}
+
+	// update Parentmap & Sitemap, removing the old link and adding the redirect target
+	self.info.Lock()
+	if parentURL := self.info.Parentmap[rawURL]; parentURL != "" {
+		parentNode := self.info.GetNodeFromSitemap(parentURL)
+		oldNode := parentNode.Links[rawURL]
+		if self.info.Parentmap[targetRawURL] == "" {
+			self.info.Parentmap[targetRawURL] = parentURL
+
+			// update Sitemap to add the final target of redirections
+			// the target keeps the source of the link that was redirected
+			parentNode.Links[targetRawURL] = &Node{
+				URL:   targetRawURL,
+				Links: make(map[string]*Node),
+			}
+			if oldNode != nil {
+				parentNode.Links[targetRawURL].Source = oldNode.Source
+			}
+
+			// remove old link of redirect
+			delete(parentNode.Links, rawURL)
+		} else if collapse {
+			delete(parentNode.Links, rawURL)
+			if parentNode.Links[targetRawURL] == nil && oldNode != nil {
+				parentNode.Links[targetRawURL] = &Node{URL: targetRawURL, Links: make(map[string]*Node), Source: oldNode.Source}
+			}
+		}
 	}
+	self.info.Unlock()
 
-	return links, nil
+	// add the redirect target to the frontier once it is in the Sitemap
+	self.schedule(next.depth, targetRawURL)
+	return true
 }
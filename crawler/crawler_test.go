@@ -1,6 +1,7 @@
 package crawler_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/terencechow/crawl/crawler"
 	"io"
@@ -9,7 +10,9 @@ import (
 	"net/url"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
+	"time"
 )
 
 type Node = crawler.Node
@@ -152,33 +155,42 @@ func TestSiteMap(t *testing.T) {
 
 	sitemap := crawler.CreateSiteMap(ts.URL, 1)
 
-	rootURL := ts.URL
+	rootURL := ts.URL + "/"
 	aboutURL := ts.URL + "/about"
 	relativeURL := ts.URL + "/relative"
 	redirectedURL := ts.URL + "/after-redirect"
 	expected := &Node{
-		URL: rootURL,
+		URL:    rootURL,
+		Status: 200,
 		Links: map[string]*Node{
 			aboutURL: &Node{
-				URL: aboutURL,
+				URL:    aboutURL,
+				Source: "a[href]",
+				Status: 200,
 				Links: map[string]*Node{
 					relativeURL: &Node{
-						URL: relativeURL,
+						URL:    relativeURL,
+						Source: "a[href]",
+						Status: 200,
 						Links: map[string]*Node{
 							aboutURL: &Node{
-								URL:   aboutURL,
-								Links: map[string]*Node{},
+								URL:    aboutURL,
+								Source: "a[href]",
+								Links:  map[string]*Node{},
 							},
 						},
 					},
 				},
 			},
 			redirectedURL: &Node{
-				URL: redirectedURL,
+				URL:    redirectedURL,
+				Source: "a[href]",
+				Status: 200,
 				Links: map[string]*Node{
 					aboutURL: &Node{
-						URL:   aboutURL,
-						Links: map[string]*Node{},
+						URL:    aboutURL,
+						Source: "a[href]",
+						Links:  map[string]*Node{},
 					},
 				},
 			},
@@ -188,3 +200,592 @@ func TestSiteMap(t *testing.T) {
 		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
 	}
 }
+
+// newPageServer returns a test server serving each path in pages. Every %[1]s in a page is replaced by the server host
+func newPageServer(pages map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		content, ok := pages[req.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(res, req)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(fmt.Sprintf(content, req.Host)))
+	}))
+}
+
+func TestConcurrentCrawlers(t *testing.T) {
+	tsA := newPageServer(map[string]string{
+		"/":  `<html><body><a href='http://%[1]s/a'>a</a></body></html>`,
+		"/a": `<html><body></body></html>`,
+	})
+	defer tsA.Close()
+	tsB := newPageServer(map[string]string{
+		"/":  `<html><body><a href='http://%[1]s/b'>b</a></body></html>`,
+		"/b": `<html><body></body></html>`,
+	})
+	defer tsB.Close()
+
+	crawlerA := crawler.New(crawler.WithWorkers(2))
+	crawlerB := crawler.New(crawler.WithWorkers(2))
+
+	sitemaps := make([]*Node, 2)
+	done := make(chan bool)
+	go func() {
+		sitemaps[0] = crawlerA.Crawl(tsA.URL)
+		done <- true
+	}()
+	go func() {
+		sitemaps[1] = crawlerB.Crawl(tsB.URL)
+		done <- true
+	}()
+	<-done
+	<-done
+
+	for i, ts := range []*httptest.Server{tsA, tsB} {
+		link := ts.URL + []string{"/a", "/b"}[i]
+		expected := &Node{
+			URL: ts.URL + "/",
+			Links: map[string]*Node{
+				link: &Node{URL: link, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			},
+			Status: 200,
+		}
+		if !reflect.DeepEqual(sitemaps[i], expected) {
+			t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemaps[i]))
+		}
+	}
+
+	// a Crawler can be reused and starts from a fresh state
+	sitemap := crawlerA.Crawl(tsB.URL)
+	if !reflect.DeepEqual(sitemap, sitemaps[1]) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", sitemaps[1], sitemap))
+	}
+}
+
+func TestCrawlContextCancel(t *testing.T) {
+	// /slow never responds until the request is aborted
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			<-req.Context().Done()
+			return
+		}
+		res.Write([]byte(fmt.Sprintf(`<html><body><a href='http://%s/slow'>slow</a></body></html>`, req.Host)))
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	sitemap, err := crawler.New(crawler.WithWorkers(2)).CrawlContext(ctx, ts.URL)
+	if err != context.DeadlineExceeded {
+		t.Error("Expected deadline exceeded error got", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Error("Expected crawl to stop promptly, took", elapsed)
+	}
+
+	slowURL := ts.URL + "/slow"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			slowURL: &Node{URL: slowURL, Links: map[string]*Node{}, Source: "a[href]"},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlStop(t *testing.T) {
+	crawl := crawler.New(crawler.WithWorkers(1))
+	fetchedAfterStop := false
+
+	// /slow is in flight when the crawl is stopped, its links are recorded but not fetched
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/robots.txt":
+			http.NotFound(res, req)
+		case "/":
+			res.Write([]byte(fmt.Sprintf(`<html><body><a href='http://%s/slow'>slow</a></body></html>`, req.Host)))
+		case "/slow":
+			crawl.Stop()
+			time.Sleep(100 * time.Millisecond)
+			res.Write([]byte(fmt.Sprintf(`<html><body><a href='http://%s/next'>next</a></body></html>`, req.Host)))
+		default:
+			fetchedAfterStop = true
+		}
+	}))
+	defer ts.Close()
+
+	sitemap, err := crawl.CrawlContext(context.Background(), ts.URL)
+	if err != crawler.ErrStopped {
+		t.Error("Expected ErrStopped got", err)
+	}
+	if fetchedAfterStop {
+		t.Error("Expected no fetch after the crawl was stopped")
+	}
+
+	slowURL := ts.URL + "/slow"
+	nextURL := ts.URL + "/next"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			slowURL: &Node{
+				URL:    slowURL,
+				Source: "a[href]",
+				Status: 200,
+				Links: map[string]*Node{
+					nextURL: &Node{URL: nextURL, Links: map[string]*Node{}, Source: "a[href]"},
+				},
+			},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlLargeSite(t *testing.T) {
+	numPages := 2000
+	var fetchesLock sync.Mutex
+	fetches := map[string]int{}
+
+	// page i links to pages 2i and 2i+1 and back to the root, so every page is reachable once
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			http.NotFound(res, req)
+			return
+		}
+		fetchesLock.Lock()
+		fetches[req.URL.Path]++
+		fetchesLock.Unlock()
+
+		var page int
+		fmt.Sscanf(req.URL.Path, "/%d", &page)
+		if page == 0 {
+			page = 1
+		}
+		content := "<html><body><a href='/'>home</a>"
+		for _, next := range []int{2 * page, 2*page + 1} {
+			if next <= numPages {
+				content += fmt.Sprintf("<a href='/%d'>next</a>", next)
+			}
+		}
+		res.Write([]byte(content + "</body></html>"))
+	}))
+	defer ts.Close()
+
+	_, err := crawler.New(crawler.WithWorkers(10)).CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	// the root plus pages 2 to numPages are fetched
+	if len(fetches) != numPages {
+		t.Error(fmt.Sprintf("Expected %v pages fetched. Got %v", numPages, len(fetches)))
+	}
+	for path, count := range fetches {
+		if count != 1 {
+			t.Error(fmt.Sprintf("Expected %s to be fetched once. Got %v", path, count))
+		}
+	}
+}
+
+func TestCrawlMaxDepth(t *testing.T) {
+	fetched := map[string]bool{}
+	var fetchedLock sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fetchedLock.Lock()
+		fetched[req.URL.Path] = true
+		fetchedLock.Unlock()
+
+		// every page links one level deeper
+		var page int
+		fmt.Sscanf(req.URL.Path, "/%d", &page)
+		res.Write([]byte(fmt.Sprintf(`<html><body><a href='/%d'>deeper</a></body></html>`, page+1)))
+	}))
+	defer ts.Close()
+
+	sitemap, err := crawler.New(crawler.WithMaxDepth(2)).CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	if fetched["/3"] {
+		t.Error("Expected /3 beyond the max depth not to be fetched")
+	}
+
+	url1, url2, url3 := ts.URL+"/1", ts.URL+"/2", ts.URL+"/3"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			url1: &Node{
+				URL:    url1,
+				Source: "a[href]",
+				Status: 200,
+				Links: map[string]*Node{
+					url2: &Node{
+						URL:    url2,
+						Source: "a[href]",
+						Status: 200,
+						Links: map[string]*Node{
+							url3: &Node{URL: url3, Links: map[string]*Node{}, Source: "a[href]"},
+						},
+					},
+				},
+			},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+// newFanOutServer returns a test server where the root links to numPages pages that have no links
+func newFanOutServer(numPages int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		content := "<html><body>"
+		if req.URL.Path == "/" || req.URL.Path == "" {
+			for i := 1; i <= numPages; i++ {
+				content += fmt.Sprintf("<a href='/%d'>page</a>", i)
+			}
+		}
+		res.Write([]byte(content + "</body></html>"))
+	}))
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	ts := newFanOutServer(10, 0)
+	defer ts.Close()
+
+	crawl := crawler.New(crawler.WithWorkers(1), crawler.WithMaxPages(4))
+	sitemap, err := crawl.CrawlContext(context.Background(), ts.URL)
+	if err != crawler.ErrMaxPages {
+		t.Error("Expected ErrMaxPages got", err)
+	}
+	if len(sitemap.Links) != 10 {
+		t.Error(fmt.Sprintf("Expected 10 links recorded. Got %v", len(sitemap.Links)))
+	}
+
+	// the root and 3 pages were fetched
+	if unfetched := crawl.Unfetched(); unfetched != 7 {
+		t.Error(fmt.Sprintf("Expected 7 unfetched urls. Got %v", unfetched))
+	}
+
+	// a budget reached by the last page is not an error
+	sitemap, err = crawler.New(crawler.WithMaxPages(11)).CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+}
+
+func TestCrawlMaxDuration(t *testing.T) {
+	ts := newFanOutServer(10, 50*time.Millisecond)
+	defer ts.Close()
+
+	crawl := crawler.New(crawler.WithWorkers(1), crawler.WithMaxDuration(120*time.Millisecond))
+	_, err := crawl.CrawlContext(context.Background(), ts.URL)
+	if err != crawler.ErrMaxDuration {
+		t.Error("Expected ErrMaxDuration got", err)
+	}
+	if unfetched := crawl.Unfetched(); unfetched == 0 || unfetched == 10 {
+		t.Error(fmt.Sprintf("Expected some urls to be fetched and some unfetched. Got %v unfetched", unfetched))
+	}
+}
+
+func TestCrawlRobots(t *testing.T) {
+	fetched := map[string]bool{}
+	var fetchedLock sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fetchedLock.Lock()
+		fetched[req.URL.Path] = true
+		fetchedLock.Unlock()
+
+		switch req.URL.Path {
+		case "/robots.txt":
+			res.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/":
+			res.Write([]byte(`<html><body><a href='/public'>public</a><a href='/private'>private</a></body></html>`))
+		}
+	}))
+	defer ts.Close()
+
+	sitemap, err := crawler.New().CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	if fetched["/private"] {
+		t.Error("Expected /private disallowed by robots.txt not to be fetched")
+	}
+
+	publicURL, privateURL := ts.URL+"/public", ts.URL+"/private"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			publicURL:  &Node{URL: publicURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			privateURL: &Node{URL: privateURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.ROBOTS_DISALLOWED},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+
+	// ignoring robots.txt crawls /private
+	crawler.New(crawler.WithIgnoreRobots(true)).Crawl(ts.URL)
+	if !fetched["/private"] {
+		t.Error("Expected /private to be fetched when ignoring robots.txt")
+	}
+}
+
+func TestCrawlRobotsDisallowedRoot(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("User-agent: *\nDisallow: /\n"))
+	}))
+	defer ts.Close()
+
+	// a server that is gone, its robots.txt can't be fetched
+	unreachable := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	unreachable.Close()
+
+	for _, rootURL := range []string{ts.URL + "/", unreachable.URL + "/"} {
+		done := make(chan *Node)
+		go func() {
+			done <- crawler.New().Crawl(rootURL)
+		}()
+		select {
+		case sitemap := <-done:
+			if sitemap.Skipped != crawler.ROBOTS_DISALLOWED || len(sitemap.Links) != 0 {
+				t.Error("Expected a disallowed root without links. Got", sitemap)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the crawl of a disallowed root to complete", rootURL)
+		}
+	}
+}
+
+func TestCrawlRobotsCancelled(t *testing.T) {
+	// robots.txt of the other host never responds until the request is aborted
+	other := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	defer other.Close()
+	ts := newPageServer(map[string]string{
+		"/": fmt.Sprintf(`<html><body><a href='%s/page'>other</a></body></html>`, other.URL),
+	})
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	anyScope := crawler.ScopeFunc(func(pageURL *url.URL, link *url.URL) bool { return true })
+	sitemap, err := crawler.New(crawler.WithScope(anyScope)).CrawlContext(ctx, ts.URL)
+	if err != context.DeadlineExceeded {
+		t.Error("Expected deadline exceeded error got", err)
+	}
+
+	// the link is left unfetched rather than disallowed by robots.txt
+	otherURL := other.URL + "/page"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			otherURL: &Node{URL: otherURL, Links: map[string]*Node{}, Source: "a[href]"},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlMetaRefresh(t *testing.T) {
+	ts := newPageServer(map[string]string{
+		"/":    `<html><body><a href='/old'>old</a><a href='/away'>away</a></body></html>`,
+		"/old": `<html><head><meta http-equiv="Refresh" content="0; URL='/new'"></head><body><a href='/lost'>lost</a></body></html>`,
+		"/new": `<html><body></body></html>`,
+		"/away": `<html><head><meta http-equiv="refresh" content="0;url=https://elsewhere.com/"></head>` +
+			`<body><a href='/kept'>kept</a></body></html>`,
+		"/kept": `<html><body></body></html>`,
+	})
+	defer ts.Close()
+
+	sitemap := crawler.New().Crawl(ts.URL)
+
+	newURL, awayURL, keptURL := ts.URL+"/new", ts.URL+"/away", ts.URL+"/kept"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			newURL: &Node{URL: newURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			awayURL: &Node{URL: awayURL, Links: map[string]*Node{
+				keptURL: &Node{URL: keptURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			}, Source: "a[href]", Status: 200},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlCanonical(t *testing.T) {
+	ts := newPageServer(map[string]string{
+		"/":             `<html><body><a href='/product'>product</a><a href='/product-copy'>duplicate</a><a href='/print'>print</a></body></html>`,
+		"/product":      `<html><head><link rel="canonical" href="/product"></head><body></body></html>`,
+		"/product-copy": `<html><head><link rel="canonical" href="/product"></head><body></body></html>`,
+		"/print":        `<html><head><link rel="canonical" href="/article"></head><body></body></html>`,
+		"/article":      `<html><body></body></html>`,
+	})
+	defer ts.Close()
+
+	productURL, duplicateURL, printURL, articleURL := ts.URL+"/product", ts.URL+"/product-copy", ts.URL+"/print", ts.URL+"/article"
+
+	// canonical links are ignored by default
+	sitemap := crawler.New().Crawl(ts.URL)
+	if sitemap.Links[duplicateURL] == nil || sitemap.Links[printURL] == nil {
+		t.Error("Expected duplicate urls to be kept by default. Got", sitemap)
+	}
+
+	sitemap = crawler.New(crawler.WithCanonical(true)).Crawl(ts.URL)
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			productURL: &Node{URL: productURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			articleURL: &Node{URL: articleURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlRobotsMeta(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/":
+			res.Write([]byte(`<html><body><a href='/nofollow' rel='nofollow'>nofollow</a><a href='/noindex'>noindex</a>` +
+				`<a href='/meta'>meta</a><a href='/header'>header</a></body></html>`))
+		case "/noindex":
+			res.Write([]byte(`<html><head><meta name="robots" content="noindex"></head><body><a href='/nofollow'>followed</a></body></html>`))
+		case "/meta":
+			res.Write([]byte(`<html><head><meta name="ROBOTS" content="nofollow"></head><body><a href='/hidden'>hidden</a></body></html>`))
+		case "/header":
+			res.Header().Add("X-Robots-Tag", "otherbot: noindex")
+			res.Header().Add("X-Robots-Tag", "crawl: none")
+			res.Write([]byte(`<html><body><a href='/secret'>secret</a></body></html>`))
+		case "/nofollow", "/hidden", "/secret":
+			res.Write([]byte(`<html><body></body></html>`))
+		default:
+			http.NotFound(res, req)
+		}
+	}))
+	defer ts.Close()
+
+	nofollowURL, noindexURL, metaURL, headerURL := ts.URL+"/nofollow", ts.URL+"/noindex", ts.URL+"/meta", ts.URL+"/header"
+	hiddenURL, secretURL := ts.URL+"/hidden", ts.URL+"/secret"
+
+	// robots meta directives are ignored by default
+	sitemap := crawler.New().Crawl(ts.URL)
+	if sitemap.Links[nofollowURL].Skipped != "" || sitemap.Links[noindexURL].NoIndex {
+		t.Error("Expected robots meta directives to be ignored by default. Got", sitemap)
+	}
+
+	sitemap = crawler.New(crawler.WithRobotsMeta(true)).Crawl(ts.URL)
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			nofollowURL: &Node{URL: nofollowURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.NOFOLLOW},
+			noindexURL: &Node{URL: noindexURL, Links: map[string]*Node{
+				nofollowURL: &Node{URL: nofollowURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			}, Source: "a[href]", NoIndex: true, Status: 200},
+			metaURL: &Node{URL: metaURL, Links: map[string]*Node{
+				hiddenURL: &Node{URL: hiddenURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.NOFOLLOW},
+			}, Source: "a[href]", Status: 200},
+			headerURL: &Node{URL: headerURL, Links: map[string]*Node{
+				secretURL: &Node{URL: secretURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.NOFOLLOW},
+			}, Source: "a[href]", NoIndex: true, Status: 200},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlURLRules(t *testing.T) {
+	ts := newPageServer(map[string]string{
+		"/":              `<html><body><a href='/blog/'>blog</a><a href='/legal/terms'>terms</a></body></html>`,
+		"/blog/":         `<html><body><a href='/blog/post'>post</a><a href='/cdn-cgi/email'>email</a></body></html>`,
+		"/blog/post":     `<html><body></body></html>`,
+		"/legal/terms":   `<html><body></body></html>`,
+		"/cdn-cgi/email": `<html><body></body></html>`,
+	})
+	defer ts.Close()
+
+	rules, _ := crawler.ParseURLRules([]string{"/blog/"}, []string{"/cdn-cgi/"})
+	blogURL, postURL, termsURL, emailURL := ts.URL+"/blog/", ts.URL+"/blog/post", ts.URL+"/legal/terms", ts.URL+"/cdn-cgi/email"
+
+	sitemap := crawler.New(crawler.WithURLRules(rules)).Crawl(ts.URL)
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			blogURL: &Node{URL: blogURL, Links: map[string]*Node{
+				postURL: &Node{URL: postURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			}, Source: "a[href]", Status: 200},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+
+	// excluded urls are listed as out of scope
+	sitemap = crawler.New(crawler.WithURLRules(rules), crawler.WithListExcluded(true)).Crawl(ts.URL)
+	expected.Links[termsURL] = &Node{URL: termsURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.OUT_OF_SCOPE}
+	expected.Links[blogURL].Links[emailURL] = &Node{URL: emailURL, Links: map[string]*Node{}, Source: "a[href]", Skipped: crawler.OUT_OF_SCOPE}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlSeeds(t *testing.T) {
+	ts := newPageServer(map[string]string{
+		"/":        `<html><body><a href='/about'>about</a><a href='/landing'>landing</a></body></html>`,
+		"/about":   `<html><body></body></html>`,
+		"/landing": `<html><body><a href='/offer'>offer</a></body></html>`,
+		"/offer":   `<html><body></body></html>`,
+	})
+	defer ts.Close()
+
+	rootURL, aboutURL, landingURL, offerURL := ts.URL+"/", ts.URL+"/about", ts.URL+"/landing", ts.URL+"/offer"
+	sitemap, err := crawler.New().CrawlSeeds(context.Background(), []string{ts.URL, landingURL, landingURL + "#top"})
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	expected := &Node{
+		URL: crawler.SEEDS,
+		Links: map[string]*Node{
+			rootURL: &Node{URL: rootURL, Links: map[string]*Node{
+				aboutURL:   &Node{URL: aboutURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+				landingURL: &Node{URL: landingURL, Links: map[string]*Node{}, Source: "a[href]"},
+			}, Status: 200},
+			landingURL: &Node{URL: landingURL, Links: map[string]*Node{
+				offerURL: &Node{URL: offerURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			}, Status: 200},
+		},
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+
+	// a single seed is the root of the Sitemap
+	sitemap, _ = crawler.New().CrawlSeeds(context.Background(), []string{landingURL})
+	if sitemap.URL != landingURL {
+		t.Error("Expected the seed to be the root. Got", sitemap.URL)
+	}
+}
@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"strings"
+)
+
+// NOFOLLOW is set as Node.Skipped on links marked rel=nofollow or found on a nofollow page
+const NOFOLLOW = "nofollow"
+
+// Directives are the robots directives of a page, from <meta name="robots"> or the X-Robots-Tag header
+type Directives struct {
+	// NoIndex pages are crawled for links but left out of the XML sitemap
+	NoIndex bool
+
+	// the links of NoFollow pages are recorded in the Sitemap but not crawled
+	NoFollow bool
+}
+
+// ParseDirectives parses a comma separated list of directives, ie "noindex, nofollow". Unknown directives are ignored
+func ParseDirectives(content string) Directives {
+	directives := Directives{}
+	for _, directive := range strings.Split(content, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			directives.NoIndex = true
+		case "nofollow":
+			directives.NoFollow = true
+		case "none":
+			directives.NoIndex = true
+			directives.NoFollow = true
+		}
+	}
+	return directives
+}
+
+// merge returns the directives of both, a directive set by either applies
+func (self Directives) merge(other Directives) Directives {
+	return Directives{
+		NoIndex:  self.NoIndex || other.NoIndex,
+		NoFollow: self.NoFollow || other.NoFollow,
+	}
+}
+
+// xRobotsTagDirectives returns the directives of X-Robots-Tag header values that apply to userAgent.
+// Values prefixed with a user agent, ie "googlebot: noindex", only apply to that user agent
+func xRobotsTagDirectives(values []string, userAgent string) Directives {
+	token := productToken(userAgent)
+	directives := Directives{}
+	for _, value := range values {
+		if idx := strings.Index(value, ":"); idx != -1 {
+			if !matchesAgent(strings.ToLower(strings.TrimSpace(value[:idx])), token) {
+				continue
+			}
+			value = value[idx+1:]
+		}
+		directives = directives.merge(ParseDirectives(value))
+	}
+	return directives
+}
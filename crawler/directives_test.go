@@ -0,0 +1,22 @@
+package crawler_test
+
+import (
+	"github.com/terencechow/crawl/crawler"
+	"testing"
+)
+
+func TestParseDirectives(t *testing.T) {
+	cases := map[string]crawler.Directives{
+		"noindex, nofollow":  {NoIndex: true, NoFollow: true},
+		"NOINDEX":            {NoIndex: true},
+		"index,nofollow":     {NoFollow: true},
+		"none":               {NoIndex: true, NoFollow: true},
+		"all, max-snippet:5": {},
+		"":                   {},
+	}
+	for content, expected := range cases {
+		if result := crawler.ParseDirectives(content); result != expected {
+			t.Errorf("Expected %+v for %q. Got %+v", expected, content, result)
+		}
+	}
+}
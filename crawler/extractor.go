@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"github.com/terencechow/crawl/parser"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Link is a link found on a page along with where it was found
+type Link struct {
+	// URL is absolute, resolved against the page it was found on
+	URL *url.URL
+
+	// element and attribute holding the link, ie a & href
+	Tag  string
+	Attr string
+
+	// Rel is REL_REFRESH or REL_CANONICAL for links the Crawler treats as redirects, empty for other links
+	Rel string
+
+	// Nofollow is true for links marked rel=nofollow
+	Nofollow bool
+}
+
+const (
+	// REL_REFRESH marks the target of a <meta http-equiv="refresh">
+	REL_REFRESH = "refresh"
+	// REL_CANONICAL marks the url of a <link rel="canonical">
+	REL_CANONICAL = "canonical"
+)
+
+// Source returns the element and attribute the link was found in, ie a[href]
+func (self Link) Source() string {
+	if self.Attr == "" {
+		return self.Tag
+	}
+	return self.Tag + "[" + self.Attr + "]"
+}
+
+// LinkExtractor finds the links in the body of a page of a given content type
+type LinkExtractor interface {
+	Extract(pageURL *url.URL, body io.Reader) ([]Link, error)
+}
+
+// DirectivesExtractor is a LinkExtractor that also finds the robots directives of a page, ie <meta name="robots">
+type DirectivesExtractor interface {
+	LinkExtractor
+	ExtractWithDirectives(pageURL *url.URL, body io.Reader) ([]Link, Directives, error)
+}
+
+// extract returns the links of a page, along with its directives if the extractor is a DirectivesExtractor
+func extract(extractor LinkExtractor, pageURL *url.URL, body io.Reader) ([]Link, Directives, error) {
+	if directivesExtractor, ok := extractor.(DirectivesExtractor); ok {
+		return directivesExtractor.ExtractWithDirectives(pageURL, body)
+	}
+	links, err := extractor.Extract(pageURL, body)
+	return links, Directives{}, err
+}
+
+// Extractors is a registry of LinkExtractor keyed by MIME type, ie text/html
+type Extractors map[string]LinkExtractor
+
+// DefaultExtractors returns a registry with the HTMLExtractor and the SitemapExtractor for XML sitemaps
+func DefaultExtractors() Extractors {
+	return Extractors{
+		"text/html":             &HTMLExtractor{},
+		"application/xhtml+xml": &HTMLExtractor{},
+		"application/xml":       &SitemapExtractor{},
+		"text/xml":              &SitemapExtractor{},
+	}
+}
+
+// For returns the LinkExtractor for a Content-Type header, nil if there is none.
+// A missing Content-Type is treated as text/html
+func (self Extractors) For(contentType string) LinkExtractor {
+	if contentType == "" {
+		return self["text/html"]
+	}
+	mimeType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	return self[strings.ToLower(mimeType)]
+}
+
+// FilterLinks returns the links in scope with urls normalized by normalizer, in the order they were found.
+// A url found more than once keeps the first link
+func FilterLinks(pageURL *url.URL, links []Link, scope Scope, normalizer parser.Normalizer) []Link {
+	seen := map[string]bool{}
+	filtered := []Link{}
+	for _, link := range links {
+		// links are normalized first so ie https://Monzo.com:443/about is in scope like https://monzo.com/about
+		normalizedURL := normalizer.Normalize(link.URL.String())
+		normalized, err := url.Parse(normalizedURL)
+		if err != nil || seen[normalizedURL] || !scope.InScope(pageURL, normalized) {
+			continue
+		}
+		seen[normalizedURL] = true
+		link.URL = normalized
+		filtered = append(filtered, link)
+	}
+	return filtered
+}
+
+// GetDomainLinks parses an html response body and returns all links within the same domain
+func GetDomainLinks(currentURL *url.URL, body io.ReadCloser) ([]string, error) {
+	links, err := (&HTMLExtractor{}).Extract(currentURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := []string{}
+	for _, link := range FilterLinks(currentURL, links, SameHostScope, parser.DefaultNormalizer) {
+		urls = append(urls, link.URL.String())
+	}
+	return urls, nil
+}
+
+// SitemapExtractor finds the <loc> urls of an XML sitemap or sitemap index
+type SitemapExtractor struct{}
+
+// Extract returns a link for every <loc> element of the sitemap
+func (self *SitemapExtractor) Extract(pageURL *url.URL, body io.Reader) ([]Link, error) {
+	links := []Link{}
+	decoder := xml.NewDecoder(body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return links, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "loc" {
+			var loc string
+			if err := decoder.DecodeElement(&loc, &start); err != nil {
+				return nil, err
+			}
+			locURL, err := url.Parse(strings.TrimSpace(loc))
+			if err != nil {
+				continue
+			}
+			links = append(links, Link{URL: pageURL.ResolveReference(locURL), Tag: "loc"})
+		}
+	}
+}
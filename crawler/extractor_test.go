@@ -0,0 +1,122 @@
+package crawler_test
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lineExtractor treats every line of a text file as a link
+type lineExtractor struct{}
+
+func (self *lineExtractor) Extract(pageURL *url.URL, body io.Reader) ([]crawler.Link, error) {
+	links := []crawler.Link{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		lineURL, err := pageURL.Parse(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, crawler.Link{URL: lineURL, Tag: "line"})
+	}
+	return links, nil
+}
+
+func TestExtractorsFor(t *testing.T) {
+	extractors := crawler.DefaultExtractors()
+	cases := map[string]crawler.LinkExtractor{
+		"":                          extractors["text/html"],
+		"text/html; charset=utf-8":  extractors["text/html"],
+		"TEXT/HTML":                 extractors["text/html"],
+		"application/xml":           extractors["application/xml"],
+		"text/css":                  nil,
+		"not a media type; charset": nil,
+	}
+	for contentType, expected := range cases {
+		if extractor := extractors.For(contentType); extractor != expected {
+			t.Error(fmt.Sprintf("Expected extractor %v for %q. Got %v", expected, contentType, extractor))
+		}
+	}
+}
+
+func TestSitemapExtractor(t *testing.T) {
+	sitemap := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://www.domain.com/about</loc></url>
+  <url><loc> /relative </loc><lastmod>2018-08-15</lastmod></url>
+</urlset>`
+	pageURL, _ := url.Parse("http://www.domain.com/sitemap.xml")
+	links, err := (&crawler.SitemapExtractor{}).Extract(pageURL, strings.NewReader(sitemap))
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	urls := []string{}
+	for _, link := range links {
+		urls = append(urls, link.URL.String())
+	}
+	expected := []string{"http://www.domain.com/about", "http://www.domain.com/relative"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, urls))
+	}
+}
+
+func TestCustomExtractorAndScope(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/":
+			res.Write([]byte(`<html><body><a href='/links.txt'>links</a><a href='/sitemap.xml'>sitemap</a></body></html>`))
+		case "/links.txt":
+			res.Header().Set("Content-Type", "text/plain")
+			res.Write([]byte("/from-text\n/private/from-text"))
+		case "/sitemap.xml":
+			res.Header().Set("Content-Type", "application/xml")
+			res.Write([]byte(`<urlset><url><loc>/from-sitemap</loc></url></urlset>`))
+		}
+	}))
+	defer ts.Close()
+
+	// skip everything under /private
+	scope := crawler.ScopeFunc(func(pageURL *url.URL, link *url.URL) bool {
+		return crawler.SameHostScope.InScope(pageURL, link) && !strings.HasPrefix(link.Path, "/private")
+	})
+	sitemap := crawler.New(
+		crawler.WithExtractor("text/plain", &lineExtractor{}),
+		crawler.WithScope(scope),
+	).Crawl(ts.URL)
+
+	textURL, sitemapURL := ts.URL+"/links.txt", ts.URL+"/sitemap.xml"
+	fromTextURL, fromSitemapURL := ts.URL+"/from-text", ts.URL+"/from-sitemap"
+	expected := &Node{
+		URL: ts.URL + "/",
+		Links: map[string]*Node{
+			textURL: &Node{
+				URL:    textURL,
+				Source: "a[href]",
+				Status: 200,
+				Links: map[string]*Node{
+					fromTextURL: &Node{URL: fromTextURL, Links: map[string]*Node{}, Source: "line", Status: 200},
+				},
+			},
+			sitemapURL: &Node{
+				URL:    sitemapURL,
+				Source: "a[href]",
+				Status: 200,
+				Links: map[string]*Node{
+					fromSitemapURL: &Node{URL: fromSitemapURL, Links: map[string]*Node{}, Source: "loc", Status: 200},
+				},
+			},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
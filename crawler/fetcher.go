@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Response is what a Fetcher returns for a url
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+
+	// URL is the final url of the response, which differs from the url fetched if the Fetcher followed redirects
+	URL string
+}
+
+// Fetcher fetches the pages and robots.txt files of a crawl.
+// It must be safe for concurrent use by every worker and should stop once ctx is done
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*Response, error)
+}
+
+// HTTPFetcher is the default Fetcher. It sends GET requests with a User-Agent header
+// and doesn't follow redirects so the Crawler can decide which redirects are part of the Sitemap
+type HTTPFetcher struct {
+	Client    *http.Client
+	UserAgent string
+}
+
+// NewHTTPFetcher returns an HTTPFetcher with a 10 second timeout
+func NewHTTPFetcher(userAgent string) *HTTPFetcher {
+	return &HTTPFetcher{
+		Client: &http.Client{
+			Timeout: time.Second * 10,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		UserAgent: userAgent,
+	}
+}
+
+// Fetch sends a GET request for rawURL
+func (self *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", self.UserAgent)
+
+	resp, err := self.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       resp.Body,
+		URL:        resp.Request.URL.String(),
+	}, nil
+}
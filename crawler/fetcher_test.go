@@ -0,0 +1,96 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFetcher serves pages from memory and records the urls it fetched.
+// A page starting with redirect: is a 301 to the url that follows
+type fakeFetcher struct {
+	pages   map[string]string
+	fetched []string
+	sync.Mutex
+}
+
+func (self *fakeFetcher) Fetch(ctx context.Context, rawURL string) (*crawler.Response, error) {
+	self.Lock()
+	self.fetched = append(self.fetched, rawURL)
+	self.Unlock()
+
+	if rawURL == "http://unreachable.com/robots.txt" {
+		return nil, errors.New("connection refused")
+	}
+	page, ok := self.pages[rawURL]
+	if !ok {
+		return &crawler.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			URL:        rawURL,
+		}, nil
+	}
+	if strings.HasPrefix(page, "redirect:") {
+		return &crawler.Response{
+			StatusCode: http.StatusMovedPermanently,
+			Header:     http.Header{"Location": []string{strings.TrimPrefix(page, "redirect:")}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			URL:        rawURL,
+		}, nil
+	}
+	return &crawler.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       ioutil.NopCloser(strings.NewReader(page)),
+		URL:        rawURL,
+	}, nil
+}
+
+func TestFetcher(t *testing.T) {
+	fetcher := &fakeFetcher{pages: map[string]string{
+		"http://www.domain.com/":      `<html><body><a href='/about'>about</a></body></html>`,
+		"http://www.domain.com/about": `<html><body><a href='/'>home</a></body></html>`,
+	}}
+
+	sitemap, err := crawler.New(crawler.WithFetcher(fetcher)).CrawlContext(context.Background(), "http://www.domain.com")
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	expected := &Node{
+		URL: "http://www.domain.com/",
+		Links: map[string]*Node{
+			"http://www.domain.com/about": &Node{
+				URL:    "http://www.domain.com/about",
+				Source: "a[href]",
+				Status: 200,
+				Links: map[string]*Node{
+					"http://www.domain.com/": &Node{URL: "http://www.domain.com/", Links: map[string]*Node{}, Source: "a[href]"},
+				},
+			},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+
+	if fetcher.fetched[0] != "http://www.domain.com/robots.txt" {
+		t.Error("Expected robots.txt to be fetched through the Fetcher first. Got", fetcher.fetched)
+	}
+
+	// robots.txt that can't be fetched disallows crawling the host
+	fetcher.fetched = nil
+	sitemap = crawler.New(crawler.WithFetcher(fetcher)).Crawl("http://unreachable.com")
+	if len(fetcher.fetched) != 1 || sitemap.Skipped != crawler.ROBOTS_DISALLOWED {
+		t.Error("Expected only robots.txt to be fetched for an unreachable host. Got", fetcher.fetched)
+	}
+}
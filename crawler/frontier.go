@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+)
+
+// a url waiting in the frontier along with its depth, the number of links followed from the root url
+type queued struct {
+	url   string
+	depth int
+}
+
+// data structure holding the urls waiting to be crawled
+// pending counts every url pushed that is not fully processed yet. A url is only done once
+// the links found on it have been pushed, so pending reaching 0 means crawling is complete
+type frontier struct {
+	urls    []queued
+	pending int
+	closed  bool
+
+	// reason the frontier was closed before completion
+	reason error
+
+	// stopped is cancelled once the frontier is closed so work in progress stops waiting, ie for a retry
+	stopped context.Context
+	stop    context.CancelFunc
+
+	// true if work in progress was given up once closed, ie a url that was not retried
+	abandoned bool
+
+	// number of urls popped and the maximum allowed, 0 for no limit
+	popped    int
+	maxPopped int
+
+	cond *sync.Cond
+	sync.Mutex
+}
+
+// newFrontier returns a frontier holding one pending unit of work for the seeding of the crawl.
+// Call done once seeded so a crawl with nothing to fetch completes. stopped is derived from ctx
+func newFrontier(ctx context.Context, maxPopped int) *frontier {
+	self := &frontier{maxPopped: maxPopped, pending: 1}
+	self.stopped, self.stop = context.WithCancel(ctx)
+	self.cond = sync.NewCond(&self.Mutex)
+	return self
+}
+
+// push adds urls to the frontier, each one must later be marked with done
+func (self *frontier) push(urls ...queued) {
+	if len(urls) == 0 {
+		return
+	}
+	self.Lock()
+	self.urls = append(self.urls, urls...)
+	self.pending += len(urls)
+	self.Unlock()
+	self.cond.Broadcast()
+}
+
+// pop blocks until a url is available and returns it.
+// Returns false once the frontier is closed, even if urls are still waiting.
+// Once maxPopped urls were returned the frontier is closed with ErrMaxPages
+func (self *frontier) pop() (queued, bool) {
+	self.Lock()
+	defer self.Unlock()
+	for len(self.urls) == 0 && !self.closed {
+		self.cond.Wait()
+	}
+	if self.closed {
+		return queued{}, false
+	}
+
+	next := self.urls[0]
+	self.urls[0] = queued{}
+	self.urls = self.urls[1:]
+
+	self.popped++
+	if self.maxPopped > 0 && self.popped >= self.maxPopped {
+		self.closeWith(ErrMaxPages)
+	}
+	return next, true
+}
+
+// done marks a popped url as fully processed
+func (self *frontier) done() {
+	self.Lock()
+	self.pending--
+	isComplete := self.pending == 0
+	if isComplete {
+		self.closed = true
+		self.stop()
+	}
+	self.Unlock()
+
+	if isComplete {
+		self.cond.Broadcast()
+	}
+}
+
+// close stops pop from returning urls, urls already popped can still be marked done.
+// reason is kept if it is the first reason given
+func (self *frontier) close(reason error) {
+	self.Lock()
+	self.closeWith(reason)
+	self.Unlock()
+}
+
+// closeWith is close for callers holding the lock
+func (self *frontier) closeWith(reason error) {
+	if !self.closed {
+		self.closed = true
+		self.reason = reason
+		self.stop()
+	}
+	self.cond.Broadcast()
+}
+
+// abandon records that work in progress was given up because the frontier was closed
+func (self *frontier) abandon() {
+	self.Lock()
+	self.abandoned = true
+	self.Unlock()
+}
+
+// err returns nil if every url pushed was fully processed, otherwise the reason the frontier was closed
+func (self *frontier) err() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.pending == 0 && !self.abandoned {
+		return nil
+	}
+	return self.reason
+}
+
+// waiting returns the number of urls pushed but never popped
+func (self *frontier) waiting() int {
+	self.Lock()
+	defer self.Unlock()
+	return len(self.urls)
+}
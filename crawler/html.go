@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"golang.org/x/net/html"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// HTML_ELEMENTS are the elements the HTMLExtractor can extract links from, along with the attribute holding the link.
+// link elements are only used with rel next, prev or alternate and form elements only when submitted with GET
+var HTML_ELEMENTS = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"iframe": "src",
+	"frame":  "src",
+	"form":   "action",
+}
+
+// EMBED_SOURCES are the sources of links that embed a page rather than link to it
+var EMBED_SOURCES = map[string]bool{
+	"iframe[src]": true,
+	"frame[src]":  true,
+}
+
+// rel values of link elements that point to another page of the site
+var navigationRels = []string{"next", "prev", "alternate"}
+
+// elements parsed whichever Elements are enabled, for the base url, meta refresh redirects and canonical links
+var documentElements = map[string]bool{"base": true, "meta": true, "link": true}
+
+// HTMLExtractor finds the links of an html page
+type HTMLExtractor struct {
+	// Elements are the names of the HTML_ELEMENTS to extract links from, only anchors if empty
+	Elements []string
+}
+
+// Extract returns the links of the enabled elements, resolved against the first <base href> of the page or pageURL without one
+func (self *HTMLExtractor) Extract(pageURL *url.URL, body io.Reader) ([]Link, error) {
+	links, _, err := self.ExtractWithDirectives(pageURL, body)
+	return links, err
+}
+
+// ExtractWithDirectives returns the links like Extract and the directives of the <meta name="robots"> elements of the page
+func (self *HTMLExtractor) ExtractWithDirectives(pageURL *url.URL, body io.Reader) ([]Link, Directives, error) {
+	enabled := map[string]bool{"a": true}
+	if len(self.Elements) > 0 {
+		enabled = map[string]bool{}
+		for _, element := range self.Elements {
+			enabled[element] = true
+		}
+	}
+
+	links := []Link{}
+	directives := Directives{}
+	baseURL, hasBase := pageURL, false
+	tokenizer := html.NewTokenizer(body)
+
+	for {
+		// iterate over tokens
+		tokenType := tokenizer.Next()
+		switch tokenType {
+
+		case html.ErrorToken:
+			err := tokenizer.Err()
+			if err != io.EOF {
+				return nil, directives, err
+			}
+			return links, directives, nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			// if token is an enabled element with attributes...
+			tagName, moreAttr := tokenizer.TagName()
+			tag := string(tagName)
+			if (!enabled[tag] && !documentElements[tag]) || !moreAttr {
+				continue
+			}
+			attrs := map[string]string{}
+			for moreAttr {
+				var key, val []byte
+				key, val, moreAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+
+			// only the first base element with an href sets the base url of the document
+			if tag == "base" {
+				if href, ok := attrs["href"]; ok && !hasBase {
+					hasBase = true
+					if hrefURL, err := url.Parse(strings.TrimSpace(href)); err == nil {
+						baseURL = resolveLink(pageURL, hrefURL)
+					} else {
+						log.Print("Error parsing base href", err)
+					}
+				}
+				continue
+			}
+
+			if tag == "meta" && strings.EqualFold(strings.TrimSpace(attrs["name"]), "robots") {
+				directives = directives.merge(ParseDirectives(attrs["content"]))
+				continue
+			}
+
+			// meta refresh redirects & canonical links are reported with their Rel for the Crawler to treat as redirects
+			if rel, rawLink := documentLink(tag, attrs); rel != "" {
+				if linkURL, err := url.Parse(rawLink); err == nil {
+					links = append(links, Link{URL: resolveLink(baseURL, linkURL), Tag: tag, Attr: HTML_ATTRS[rel], Rel: rel})
+				} else {
+					log.Print("Error parsing", err)
+				}
+				continue
+			}
+			if !enabled[tag] {
+				continue
+			}
+
+			// and it has the attribute holding the link
+			attr := HTML_ELEMENTS[tag]
+			val, ok := attrs[attr]
+			if !ok || !followElement(tag, attrs) {
+				continue
+			}
+
+			// grab url from the attribute, a link that can't be parsed is skipped
+			nextURL, err := url.Parse(strings.TrimSpace(val))
+			if err != nil {
+				log.Print("Error parsing", err)
+				continue
+			}
+
+			links = append(links, Link{URL: resolveLink(baseURL, nextURL), Tag: tag, Attr: attr, Nofollow: hasRel(attrs, NOFOLLOW)})
+		}
+	}
+}
+
+// HTML_ATTRS are the attributes holding the links of each Rel
+var HTML_ATTRS = map[string]string{
+	REL_REFRESH:   "content",
+	REL_CANONICAL: "href",
+}
+
+// documentLink returns the Rel and url of a meta refresh or canonical link element, an empty Rel for other elements
+func documentLink(tag string, attrs map[string]string) (string, string) {
+	switch {
+	case tag == "meta" && strings.EqualFold(strings.TrimSpace(attrs["http-equiv"]), "refresh"):
+		if refreshURL, ok := parseRefresh(attrs["content"]); ok {
+			return REL_REFRESH, refreshURL
+		}
+	case tag == "link" && hasRel(attrs, REL_CANONICAL):
+		if href := strings.TrimSpace(attrs["href"]); href != "" {
+			return REL_CANONICAL, href
+		}
+	}
+	return "", ""
+}
+
+// parseRefresh returns the url of the content of a meta refresh, ie "0; url=/new".
+// Returns false for refreshes reloading the page itself
+func parseRefresh(content string) (string, bool) {
+	// skip the delay
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return "", false
+	}
+	content = strings.TrimLeft(content[i+1:], " \t;,")
+
+	// url= is optional
+	if len(content) >= 3 && strings.EqualFold(content[:3], "url") {
+		if rest := strings.TrimLeft(content[3:], " \t"); strings.HasPrefix(rest, "=") {
+			content = strings.TrimLeft(rest[1:], " \t")
+		}
+	}
+	if len(content) > 0 && (content[0] == '\'' || content[0] == '"') {
+		if end := strings.IndexByte(content[1:], content[0]); end >= 0 {
+			content = content[1 : end+1]
+		} else {
+			content = content[1:]
+		}
+	}
+
+	content = strings.TrimSpace(content)
+	return content, content != ""
+}
+
+// hasRel returns true if the rel attribute contains one of rels
+func hasRel(attrs map[string]string, rels ...string) bool {
+	for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		for _, wanted := range rels {
+			if rel == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// followElement returns false for link elements that aren't navigation and forms that aren't submitted with GET
+func followElement(tag string, attrs map[string]string) bool {
+	switch tag {
+	case "link":
+		return hasRel(attrs, navigationRels...)
+	case "form":
+		method := strings.ToLower(strings.TrimSpace(attrs["method"]))
+		return method == "" || method == "get"
+	}
+	return true
+}
+
+// resolveLink makes a link found on a page absolute
+func resolveLink(pageURL *url.URL, nextURL *url.URL) *url.URL {
+	// handle if relative path
+	nextURL = resolveIfRelativePath(pageURL, nextURL)
+
+	// if host matches but scheme missing set the scheme
+	if nextURL.Host == pageURL.Host && nextURL.Scheme == "" {
+		nextURL.Scheme = pageURL.Scheme
+	}
+	return nextURL
+}
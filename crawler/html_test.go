@@ -0,0 +1,130 @@
+package crawler_test
+
+import (
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTMLExtractorElements(t *testing.T) {
+	page := `<html>
+  <head>
+    <link rel="next" href="/page-2">
+    <link rel="stylesheet" href="/style.css">
+    <link rel="Alternate" hreflang="fr" href="/fr/" />
+  </head>
+  <body>
+    <a href="/bad%zz">unparseable</a>
+    <iframe src="http://bad host/"></iframe>
+    <a href="/anchor">anchor</a>
+    <map><area href="/area" alt="area"></map>
+    <iframe src="/iframe"></iframe>
+    <frameset><frame src="/frame"></frameset>
+    <form action="/search"><input name="q"></form>
+    <form action="/login" method="POST"><input name="user"></form>
+    <img src="/image.png">
+  </body>
+</html>`
+	pageURL, _ := url.Parse("http://www.domain.com/page")
+
+	extractor := &crawler.HTMLExtractor{Elements: []string{"a", "area", "link", "iframe", "frame", "form"}}
+	links, err := extractor.Extract(pageURL, strings.NewReader(page))
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	sources := map[string]string{}
+	for _, link := range links {
+		sources[link.URL.String()] = link.Source()
+	}
+	expected := map[string]string{
+		"http://www.domain.com/page-2": "link[href]",
+		"http://www.domain.com/fr/":    "link[href]",
+		"http://www.domain.com/anchor": "a[href]",
+		"http://www.domain.com/area":   "area[href]",
+		"http://www.domain.com/iframe": "iframe[src]",
+		"http://www.domain.com/frame":  "frame[src]",
+		"http://www.domain.com/search": "form[action]",
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sources))
+	}
+
+	// only anchors by default
+	links, _ = (&crawler.HTMLExtractor{}).Extract(pageURL, strings.NewReader(page))
+	if len(links) != 1 || links[0].Source() != "a[href]" {
+		t.Error("Expected only the anchor by default. Got", links)
+	}
+}
+
+func TestHTMLExtractorBase(t *testing.T) {
+	page := `<html>
+  <head>
+    <base href="/cms/section/">
+    <base href="/ignored/">
+  </head>
+  <body>
+    <a href="page">relative</a>
+    <a href="../other">parent</a>
+    <a href="/absolute">absolute</a>
+    <a href="https://www.domain.com/full">full</a>
+  </body>
+</html>`
+	pageURL, _ := url.Parse("http://www.domain.com/index.php?id=3")
+
+	links, err := (&crawler.HTMLExtractor{}).Extract(pageURL, strings.NewReader(page))
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	result := []string{}
+	for _, link := range links {
+		result = append(result, link.URL.String())
+	}
+	expected := []string{
+		"http://www.domain.com/cms/section/page",
+		"http://www.domain.com/cms/other",
+		"http://www.domain.com/absolute",
+		"https://www.domain.com/full",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, result))
+	}
+}
+
+func TestHTMLExtractorRedirects(t *testing.T) {
+	pageURL, _ := url.Parse("http://www.domain.com/dir/page")
+	cases := map[string]string{
+		`<meta http-equiv="refresh" content="0; url=/new">`:             "http://www.domain.com/new",
+		`<meta http-equiv="Refresh" content="5;URL='other'">`:           "http://www.domain.com/dir/other",
+		`<meta http-equiv="refresh" content="0, url = &quot;/q&quot;">`: "http://www.domain.com/q",
+		`<meta http-equiv="refresh" content="0; https://a.com/">`:       "https://a.com/",
+		`<meta http-equiv="refresh" content="30">`:                      "",
+		`<meta name="refresh" content="0; url=/new">`:                   "",
+		`<link rel="canonical" href="/canonical">`:                      "http://www.domain.com/canonical",
+	}
+	for page, expected := range cases {
+		links, err := (&crawler.HTMLExtractor{}).Extract(pageURL, strings.NewReader(page))
+		if err != nil {
+			t.Error("Expected no error got", err)
+		}
+		result := ""
+		for _, link := range links {
+			if link.Rel == crawler.REL_REFRESH || link.Rel == crawler.REL_CANONICAL {
+				result = link.URL.String()
+			}
+		}
+		if result != expected {
+			t.Error(fmt.Sprintf("Expected %q for %s. Got %q", expected, page, result))
+		}
+	}
+}
+
+func TestNodeIsEmbed(t *testing.T) {
+	if !(&Node{Source: "iframe[src]"}).IsEmbed() || (&Node{Source: "a[href]"}).IsEmbed() {
+		t.Error("Expected only iframe and frame links to be embeds")
+	}
+}
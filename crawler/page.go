@@ -0,0 +1,53 @@
+package crawler
+
+// Page is the record of a url once crawled, passed to the callback of WithOnPage
+type Page struct {
+	URL string
+
+	// Parent is the url of the page the url was first found on, empty for the seeds
+	Parent string
+
+	// Depth is the number of links followed from a seed to the url
+	Depth int
+
+	// Status is the HTTP status code of the last response, 0 if the request failed
+	Status int
+
+	// Source & NoIndex are those of the node of the url in the Sitemap
+	Source  string
+	NoIndex bool
+
+	// Links are the urls of the links added to the Sitemap under the page, in the order they were found
+	Links []string
+
+	// Error is the reason the page could not be crawled, empty if it was crawled or redirected
+	Error string
+}
+
+// reportPage calls the onPage callback with the record of a crawled url
+func (self *Crawler) reportPage(next queued, status int, links []string, err error) {
+	if self.onPage == nil {
+		return
+	}
+
+	page := Page{URL: next.url, Depth: next.depth, Status: status, Links: links}
+	if err != nil {
+		page.Error = err.Error()
+	}
+	self.info.Lock()
+	if parent := self.info.Parentmap[next.url]; parent != ROOT && parent != SEEDS {
+		page.Parent = parent
+	}
+	if node := self.info.GetNodeFromSitemap(next.url); node != nil {
+		page.Source = node.Source
+		page.NoIndex = node.NoIndex
+	}
+	self.info.Unlock()
+	if page.Links == nil {
+		page.Links = []string{}
+	}
+
+	self.onPageLock.Lock()
+	defer self.onPageLock.Unlock()
+	self.onPage(page)
+}
@@ -0,0 +1,66 @@
+package crawler_test
+
+import (
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlOnPage(t *testing.T) {
+	fetcher := &fakeFetcher{pages: map[string]string{
+		"http://www.domain.com/":      `<html><body><a href='/about'>about</a><a href='/old'>old</a></body></html>`,
+		"http://www.domain.com/about": `<html><body><a href='/missing'>missing</a><a href='http://www.domain.com'>home</a></body></html>`,
+		"http://www.domain.com/old":   `redirect:http://www.domain.com/new`,
+		"http://www.domain.com/new":   `<html><body></body></html>`,
+	}}
+
+	pages := map[string]crawler.Page{}
+	onPage := func(page crawler.Page) {
+		pages[page.URL] = page
+	}
+	crawler.New(crawler.WithFetcher(fetcher), crawler.WithWorkers(4), crawler.WithOnPage(onPage)).Crawl("http://www.domain.com")
+
+	expected := map[string]crawler.Page{
+		"http://www.domain.com/": crawler.Page{
+			URL:    "http://www.domain.com/",
+			Status: 200,
+			Links:  []string{"http://www.domain.com/about", "http://www.domain.com/old"},
+		},
+		"http://www.domain.com/about": crawler.Page{
+			URL:    "http://www.domain.com/about",
+			Parent: "http://www.domain.com/",
+			Depth:  1,
+			Status: 200,
+			Source: "a[href]",
+			Links:  []string{"http://www.domain.com/missing", "http://www.domain.com/"},
+		},
+		"http://www.domain.com/old": crawler.Page{
+			URL:    "http://www.domain.com/old",
+			Parent: "http://www.domain.com/",
+			Depth:  1,
+			Status: 301,
+			Links:  []string{},
+		},
+		"http://www.domain.com/new": crawler.Page{
+			URL:    "http://www.domain.com/new",
+			Parent: "http://www.domain.com/",
+			Depth:  1,
+			Status: 200,
+			Source: "a[href]",
+			Links:  []string{},
+		},
+		"http://www.domain.com/missing": crawler.Page{
+			URL:    "http://www.domain.com/missing",
+			Parent: "http://www.domain.com/about",
+			Depth:  2,
+			Status: 404,
+			Source: "a[href]",
+			Links:  []string{},
+			Error:  "404 Not Found",
+		},
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Error(fmt.Sprintf("Expected %+v. Got %+v", expected, pages))
+	}
+}
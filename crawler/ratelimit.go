@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// token bucket limiting the requests sent to one host
+// tokens can go negative, each request reserves a token and waits until it would have been available
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	sync.Mutex
+}
+
+// reserve takes a token and returns how long to wait before sending the request
+func (self *bucket) reserve(rate float64, burst float64) time.Duration {
+	self.Lock()
+	defer self.Unlock()
+
+	// a Crawl-delay can tighten the limits once robots.txt is known
+	self.rate = rate
+	if self.burst == 0 || burst < self.burst {
+		self.burst = burst
+	}
+
+	now := time.Now()
+	if self.last.IsZero() {
+		self.tokens = self.burst
+	} else {
+		self.tokens += now.Sub(self.last).Seconds() * self.rate
+		if self.tokens > self.burst {
+			self.tokens = self.burst
+		}
+	}
+	self.last = now
+
+	self.tokens--
+	if self.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-self.tokens / self.rate * float64(time.Second))
+}
+
+// data structure holding a token bucket per host, shared by every worker of a crawl
+type rateLimiter struct {
+	// requests per second and burst allowed per host, a rate of 0 means no limit
+	rate  float64
+	burst int
+
+	buckets map[string]*bucket
+	sync.Mutex
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// wait blocks until a request can be sent to host or ctx is done.
+// A crawlDelay from robots.txt lowers the rate to one request per crawlDelay without burst
+func (self *rateLimiter) wait(ctx context.Context, host string, crawlDelay time.Duration) error {
+	rate, burst := self.rate, float64(self.burst)
+	if crawlDelay > 0 {
+		if delayRate := 1 / crawlDelay.Seconds(); rate == 0 || delayRate < rate {
+			rate = delayRate
+		}
+		burst = 1
+	}
+	if rate == 0 {
+		return nil
+	}
+
+	self.Lock()
+	hostBucket, ok := self.buckets[host]
+	if !ok {
+		hostBucket = &bucket{}
+		self.buckets[host] = hostBucket
+	}
+	self.Unlock()
+
+	delay := hostBucket.reserve(rate, burst)
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitForHost blocks until the rate limit of the host of currentURL allows another request
+func (self *Crawler) waitForHost(currentURL *url.URL) error {
+	var crawlDelay time.Duration
+	if !self.ignoreRobots {
+		if robots := self.robotsFor(currentURL); robots != nil {
+			crawlDelay = robots.CrawlDelay
+		}
+	}
+	return self.limiter.wait(self.ctx, currentURL.Host, crawlDelay)
+}
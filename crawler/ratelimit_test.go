@@ -0,0 +1,52 @@
+package crawler_test
+
+import (
+	"context"
+	"github.com/terencechow/crawl/crawler"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	ts := newFanOutServer(4, 0)
+	defer ts.Close()
+
+	// robots.txt and 5 pages at 20 requests per second without burst take at least 250ms
+	start := time.Now()
+	_, err := crawler.New(crawler.WithWorkers(4), crawler.WithRateLimit(20, 1)).CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Error("Expected requests to be rate limited, took", elapsed)
+	}
+
+	// with a burst of 6 robots.txt and the 5 pages are requested right away
+	start = time.Now()
+	crawler.New(crawler.WithWorkers(4), crawler.WithRateLimit(1, 6)).Crawl(ts.URL)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Error("Expected requests to burst, took", elapsed)
+	}
+}
+
+func TestRateLimitCrawlDelay(t *testing.T) {
+	fanOut := newFanOutServer(3, 0)
+	defer fanOut.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			res.Write([]byte("User-agent: *\nCrawl-delay: 0.1\n"))
+			return
+		}
+		fanOut.Config.Handler.ServeHTTP(res, req)
+	}))
+	defer ts.Close()
+
+	// 4 pages one Crawl-delay apart take at least 300ms even with a high rate & burst
+	start := time.Now()
+	crawler.New(crawler.WithWorkers(4), crawler.WithRateLimit(100, 10)).Crawl(ts.URL)
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Error("Expected Crawl-delay to limit requests, took", elapsed)
+	}
+}
@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides which failed requests are retried and how long to wait between attempts
+type RetryPolicy struct {
+	// MaxAttempts is the number of requests made for a url including the first one, 1 disables retries
+	MaxAttempts int
+
+	// BaseBackoff is the delay before the first retry, doubled after every attempt up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// MaxRetryAfter is the longest Retry-After honored, urls asking to wait longer are not retried
+	MaxRetryAfter time.Duration
+
+	// Jitter randomizes each delay by up to this fraction of it, between 0 and 1
+	Jitter float64
+
+	// RetryStatuses are the response status codes that are retried
+	RetryStatuses []int
+
+	// RetryNetworkErrors retries requests that failed without a response, ie timeouts or refused connections
+	RetryNetworkErrors bool
+}
+
+// DefaultRetryPolicy retries server errors, 429 Too Many Requests and network errors with delays of 1s, 2s, 4s, 8s and 16s.
+// A Retry-After header of up to 5 minutes replaces the delay
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:        6,
+	BaseBackoff:        time.Second,
+	MaxBackoff:         16 * time.Second,
+	MaxRetryAfter:      5 * time.Minute,
+	Jitter:             0.1,
+	RetryStatuses:      []int{429, 500, 502, 503, 504},
+	RetryNetworkErrors: true,
+}
+
+// retryStatus returns true if a response with statusCode is retried
+func (self RetryPolicy) retryStatus(statusCode int) bool {
+	for _, retryStatus := range self.RetryStatuses {
+		if statusCode == retryStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt, after attempt attempts were made
+func (self RetryPolicy) backoff(attempt int) time.Duration {
+	delay := self.BaseBackoff
+	for i := 1; i < attempt && delay < self.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > self.MaxBackoff {
+		delay = self.MaxBackoff
+	}
+	if self.Jitter > 0 {
+		delay += time.Duration(float64(delay) * self.Jitter * (rand.Float64()*2 - 1))
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header, either a number of seconds or an HTTP-date.
+// Returns false if the header is missing or invalid
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// Attempts returns the number of requests the last crawl made for a url, including retries
+func (self *Crawler) Attempts(rawURL string) int {
+	self.visitState.Lock()
+	defer self.visitState.Unlock()
+	return self.visitState.attempts[rawURL]
+}
+
+// fetchWithRetries fetches a url and retries failures as the RetryPolicy allows.
+// Returns the last response or error once the request succeeds, isn't retryable or the attempts are used up.
+// If Retry-After asks to wait longer than MaxRetryAfter the url is not retried, nor once the crawl is stopped or out of budget
+func (self *Crawler) fetchWithRetries(rawURL string) (*Response, error) {
+	policy := self.retryPolicy
+	for attempt := 1; ; attempt++ {
+		self.visitState.Lock()
+		self.visitState.attempts[rawURL] = attempt
+		self.visitState.Unlock()
+
+		resp, err := self.get(rawURL)
+
+		retry := false
+		delay := policy.backoff(attempt)
+		if err != nil {
+			// a cancelled crawl is not a network error
+			retry = policy.RetryNetworkErrors && self.ctx.Err() == nil
+		} else if policy.retryStatus(resp.StatusCode) {
+			retry = true
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = retryAfter
+				retry = retryAfter <= policy.MaxRetryAfter
+			}
+		}
+		if !retry || attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("Failed %s on %s. Retrying in %v \n", err, rawURL, delay)
+		} else {
+			log.Printf("Failed %v on %s. Retrying in %v \n", resp.StatusCode, rawURL, delay)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-self.frontier.stopped.Done():
+			timer.Stop()
+			if err := self.ctx.Err(); err != nil {
+				return nil, err
+			}
+			self.frontier.abandon()
+			return nil, self.frontier.err()
+		}
+	}
+}
+
+// get fetches a url once the rate limit of its host allows it
+func (self *Crawler) get(rawURL string) (*Response, error) {
+	currentURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for our turn to send a request to this host
+	if err := self.waitForHost(currentURL); err != nil {
+		return nil, err
+	}
+
+	return self.fetcher.Fetch(self.ctx, rawURL)
+}
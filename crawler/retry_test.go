@@ -0,0 +1,147 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var attemptsLock sync.Mutex
+	attempts := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		attemptsLock.Lock()
+		attempts[req.URL.Path]++
+		attempt := attempts[req.URL.Path]
+		attemptsLock.Unlock()
+
+		switch req.URL.Path {
+		case "/":
+			res.Write([]byte(`<html><body>
+				<a href='/flaky'>fails twice</a>
+				<a href='/missing'>not found</a>
+				<a href='/down'>always fails</a>
+				<a href='/later'>retry after longer than the backoff</a>
+				<a href='/too-long'>retry after too long</a>
+			</body></html>`))
+		case "/flaky":
+			if attempt <= 2 {
+				res.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			res.Write([]byte(`<html><body><a href='/found'>found</a></body></html>`))
+		case "/later":
+			if attempt == 1 {
+				res.Header().Set("Retry-After", "1")
+				res.WriteHeader(http.StatusTooManyRequests)
+			}
+		case "/down":
+			res.WriteHeader(http.StatusBadGateway)
+		case "/too-long":
+			res.Header().Set("Retry-After", "3600")
+			res.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.NotFound(res, req)
+		}
+	}))
+	defer ts.Close()
+
+	policy := crawler.DefaultRetryPolicy
+	policy.MaxAttempts = 4
+	policy.BaseBackoff = 10 * time.Millisecond
+	policy.MaxBackoff = 40 * time.Millisecond
+	policy.MaxRetryAfter = 2 * time.Second
+	crawl := crawler.New(crawler.WithRetryPolicy(policy))
+	sitemap, err := crawl.CrawlContext(context.Background(), ts.URL)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	expectedAttempts := map[string]int{
+		"/flaky":    3,
+		"/missing":  1,
+		"/down":     4,
+		"/later":    2,
+		"/too-long": 1,
+		"/found":    1,
+	}
+	for path, expected := range expectedAttempts {
+		if attempts := crawl.Attempts(ts.URL + path); attempts != expected {
+			t.Error(fmt.Sprintf("Expected %v attempts for %s. Got %v", expected, path, attempts))
+		}
+	}
+
+	flakyNode := sitemap.Links[ts.URL+"/flaky"]
+	if flakyNode == nil || flakyNode.Links[ts.URL+"/found"] == nil {
+		t.Error("Expected links of /flaky to be crawled once it succeeded")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	// Retry-After as seconds and as an HTTP-date
+	for _, useDate := range []bool{false, true} {
+		retryAfter := "1"
+		if useDate {
+			retryAfter = time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+		}
+
+		var attemptsLock sync.Mutex
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/robots.txt" {
+				http.NotFound(res, req)
+				return
+			}
+			attemptsLock.Lock()
+			attempts++
+			attempt := attempts
+			attemptsLock.Unlock()
+
+			if attempt == 1 {
+				res.Header().Set("Retry-After", retryAfter)
+				res.WriteHeader(http.StatusTooManyRequests)
+			}
+		}))
+
+		policy := crawler.DefaultRetryPolicy
+		policy.BaseBackoff = time.Millisecond
+		crawl := crawler.New(crawler.WithRetryPolicy(policy))
+
+		start := time.Now()
+		crawl.Crawl(ts.URL)
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Error(fmt.Sprintf("Expected Retry-After %s to delay the retry, took %v", retryAfter, elapsed))
+		}
+		if attempts := crawl.Attempts(ts.URL + "/"); attempts != 2 {
+			t.Error(fmt.Sprintf("Expected 2 attempts. Got %v", attempts))
+		}
+		ts.Close()
+	}
+}
+
+func TestRetryMaxDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			http.NotFound(res, req)
+			return
+		}
+		res.Header().Set("Retry-After", "2")
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// retries are not waited for once the duration budget is reached
+	start := time.Now()
+	_, err := crawler.New(crawler.WithMaxDuration(200*time.Millisecond)).CrawlContext(context.Background(), ts.URL)
+	if err != crawler.ErrMaxDuration {
+		t.Error("Expected max duration error got", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Expected crawl to stop at the max duration, took", elapsed)
+	}
+}
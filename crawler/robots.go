@@ -0,0 +1,279 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DEFAULT_USER_AGENT is sent with every request and used to pick the robots.txt rules that apply to us
+const DEFAULT_USER_AGENT = "crawl"
+
+// ROBOTS_DISALLOWED is set as Node.Skipped on urls robots.txt does not allow us to crawl
+const ROBOTS_DISALLOWED = "disallowed by robots.txt"
+
+// MAX_ROBOTS_SIZE is the number of bytes of robots.txt that are parsed, the rest is ignored
+const MAX_ROBOTS_SIZE = 500 * 1024
+
+// a single Allow or Disallow line of robots.txt
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// Robots holds the rules of a robots.txt file that apply to one user agent
+type Robots struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration
+}
+
+// group of rules that follow one or more User-agent lines
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobots parses a robots.txt file and keeps the rules of the group matching userAgent.
+// The group with the longest user agent matching the product token of userAgent is used, falling back to the * group.
+// Groups naming the same user agent are merged
+func ParseRobots(body io.Reader, userAgent string) *Robots {
+	groups := []*robotsGroup{}
+	var current *robotsGroup
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(io.LimitReader(body, MAX_ROBOTS_SIZE))
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		// a UTF-8 byte order mark would hide the first key
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+
+		switch key {
+		case "user-agent":
+			// consecutive User-agent lines share the same group
+			if !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// an empty Disallow allows everything, which is the same as having no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); current != nil && err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		lastWasAgent = false
+	}
+
+	token := productToken(userAgent)
+
+	// find the length of the most specific agent matching our token
+	bestMatch := -1
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if matchesAgent(agent, token) && len(agent) > bestMatch {
+				bestMatch = len(agent)
+			}
+		}
+	}
+
+	robots := &Robots{}
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if matchesAgent(agent, token) && len(agent) == bestMatch {
+				robots.rules = append(robots.rules, group.rules...)
+				if group.crawlDelay > robots.CrawlDelay {
+					robots.CrawlDelay = group.crawlDelay
+				}
+				break
+			}
+		}
+	}
+	return robots
+}
+
+// productToken returns the lowercase user agent up to the version, ie Crawl/1.0 -> crawl
+func productToken(userAgent string) string {
+	token := strings.ToLower(userAgent)
+	if idx := strings.IndexAny(token, "/ "); idx != -1 {
+		token = token[:idx]
+	}
+	return token
+}
+
+// matchesAgent returns true if the agent of a User-agent line applies to the product token
+func matchesAgent(agent string, token string) bool {
+	return agent == "*" || (agent != "" && strings.Contains(token, agent))
+}
+
+// Allowed returns true if the rules allow crawling path, which includes the query string.
+// The longest matching pattern wins and Allow wins over Disallow for patterns of equal length
+func (self *Robots) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range self.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// matchRobotsPattern matches path against a robots.txt pattern
+// where * matches any sequence of characters and a trailing $ anchors the end of the path
+func matchRobotsPattern(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+
+	// the first part must be a prefix of the path
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	// every other part must appear in order, matching as early as possible
+	for i := 1; i < len(parts); i++ {
+		// the last part of an anchored pattern must end the path
+		if anchored && i == len(parts)-1 {
+			return strings.HasSuffix(rest, parts[i])
+		}
+		idx := strings.Index(rest, parts[i])
+		if idx == -1 {
+			return false
+		}
+		rest = rest[idx+len(parts[i]):]
+	}
+
+	return !anchored || rest == ""
+}
+
+// allows everything, used when robots.txt doesn't exist
+var allowAll = &Robots{}
+
+// disallows everything, used when robots.txt can't be fetched
+var disallowAll = &Robots{rules: []robotsRule{{pattern: "/", allow: false}}}
+
+// robots.txt rules of a host, ready is closed once they have been fetched
+type robotsEntry struct {
+	robots *Robots
+	ready  chan bool
+}
+
+// robotsFor returns the robots.txt rules for the host of currentURL, fetching them once per host.
+// Returns nil if the crawl was cancelled before they were known, nothing is cached then
+func (self *Crawler) robotsFor(currentURL *url.URL) *Robots {
+	key := currentURL.Scheme + "://" + currentURL.Host
+
+	self.robotsLock.Lock()
+	entry, fetched := self.robots[key]
+	if !fetched {
+		entry = &robotsEntry{ready: make(chan bool)}
+		self.robots[key] = entry
+	}
+	self.robotsLock.Unlock()
+
+	if fetched {
+		<-entry.ready
+		return entry.robots
+	}
+
+	entry.robots = self.fetchRobots(key + "/robots.txt")
+	if self.ctx.Err() != nil {
+		entry.robots = nil
+		self.robotsLock.Lock()
+		delete(self.robots, key)
+		self.robotsLock.Unlock()
+	}
+	close(entry.ready)
+	return entry.robots
+}
+
+// fetchRobots fetches and parses a robots.txt file, following up to 5 redirects.
+// A missing robots.txt allows everything, while server errors or an unreachable host disallow everything
+func (self *Crawler) fetchRobots(robotsURL string) *Robots {
+	for redirects := 0; redirects <= 5; redirects++ {
+		robotsRequestURL, err := url.Parse(robotsURL)
+		if err != nil {
+			log.Print("Error parsing robots.txt URL", err)
+			return disallowAll
+		}
+
+		// robots.txt is fetched before the Crawl-delay is known, so only the configured rate applies
+		if err := self.limiter.wait(self.ctx, robotsRequestURL.Host, 0); err != nil {
+			return disallowAll
+		}
+
+		resp, err := self.fetcher.Fetch(self.ctx, robotsURL)
+		if err != nil {
+			log.Print("Error fetching robots.txt", err)
+			return disallowAll
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			robots := ParseRobots(resp.Body, self.userAgent)
+			resp.Body.Close()
+			return robots
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			nextURL, err := robotsRequestURL.Parse(resp.Header.Get("location"))
+			if err != nil {
+				return allowAll
+			}
+			robotsURL = nextURL.String()
+		case resp.StatusCode >= 400 && resp.StatusCode < 500:
+			return allowAll
+		default:
+			return disallowAll
+		}
+	}
+	return allowAll
+}
+
+// robotsAllowed returns true if robots.txt allows crawling rawURL or robots.txt is ignored.
+// Urls of hosts whose robots.txt is unknown because the crawl was cancelled are not marked as disallowed, they are left unfetched
+func (self *Crawler) robotsAllowed(rawURL string) bool {
+	if self.ignoreRobots {
+		return true
+	}
+	currentURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	robots := self.robotsFor(currentURL)
+	return robots == nil || robots.Allowed(currentURL.RequestURI())
+}
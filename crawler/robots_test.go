@@ -0,0 +1,103 @@
+package crawler_test
+
+import (
+	"github.com/terencechow/crawl/crawler"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	robotsTxt := `
+# comments are ignored
+User-agent: *
+Disallow: /private
+Allow: /private/public
+
+User-agent: crawl
+User-agent: other
+Disallow: /admin # trailing comment
+Disallow: /*.pdf$
+Disallow: /search*q=
+Allow: /admin/help
+Crawl-delay: 2.5
+
+User-agent: crawl
+Disallow: /tmp
+`
+	robots := crawler.ParseRobots(strings.NewReader(robotsTxt), "crawl/1.0")
+	cases := map[string]bool{
+		"/":               true,
+		"/private":        true,
+		"/admin":          false,
+		"/admin/users":    false,
+		"/admin/help":     true,
+		"/tmp/file":       false,
+		"/file.pdf":       false,
+		"/file.pdf?x=1":   true,
+		"/search?q=crawl": false,
+		"/search":         true,
+		"/robots.txt":     true,
+	}
+	for path, expected := range cases {
+		if allowed := robots.Allowed(path); allowed != expected {
+			t.Errorf("Expected Allowed(%s) to be %v for crawl", path, expected)
+		}
+	}
+	if robots.CrawlDelay != 2500*time.Millisecond {
+		t.Error("Expected crawl delay of 2.5s got", robots.CrawlDelay)
+	}
+
+	// other user agents fall back to the * group
+	robots = crawler.ParseRobots(strings.NewReader(robotsTxt), "somebot")
+	cases = map[string]bool{
+		"/admin":               true,
+		"/private":             false,
+		"/private/public":      true,
+		"/private/public/page": true,
+	}
+	for path, expected := range cases {
+		if allowed := robots.Allowed(path); allowed != expected {
+			t.Errorf("Expected Allowed(%s) to be %v for somebot", path, expected)
+		}
+	}
+	if robots.CrawlDelay != 0 {
+		t.Error("Expected no crawl delay got", robots.CrawlDelay)
+	}
+}
+
+func TestParseRobotsLongestMatch(t *testing.T) {
+	robotsTxt := `
+User-agent: *
+Disallow: /page
+Allow: /page
+Disallow: /*.html
+Allow: /docs/*.html
+Disallow: /
+Allow: /$
+`
+	robots := crawler.ParseRobots(strings.NewReader(robotsTxt), "crawl")
+	cases := map[string]bool{
+		"/":                true,
+		"/index":           false,
+		"/page":            true,
+		"/about.html":      false,
+		"/docs/intro.html": true,
+	}
+	for path, expected := range cases {
+		if allowed := robots.Allowed(path); allowed != expected {
+			t.Errorf("Expected Allowed(%s) to be %v", path, expected)
+		}
+	}
+}
+
+func TestParseRobotsByteOrderMark(t *testing.T) {
+	robotsTxt := "\ufeffUser-agent: *\nDisallow: /private\n"
+	robots := crawler.ParseRobots(strings.NewReader(robotsTxt), "crawl")
+	if robots.Allowed("/private") {
+		t.Error("Expected the group after a byte order mark to disallow /private")
+	}
+	if !robots.Allowed("/public") {
+		t.Error("Expected /public to be allowed")
+	}
+}
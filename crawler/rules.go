@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// OUT_OF_SCOPE is set as Node.Skipped on urls excluded by the URLRules when excluded urls are listed
+const OUT_OF_SCOPE = "out of scope"
+
+// URLRules are include and exclude patterns the path and query of a url must satisfy to be crawled.
+// A pattern is a path prefix like /blog/, a glob like /*/private/* when it contains * or ?,
+// or a regular expression when prefixed with re:, ie re:\.pdf$
+type URLRules struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// ParseURLRules compiles the include and exclude patterns, returning an error for invalid regular expressions
+func ParseURLRules(include []string, exclude []string) (*URLRules, error) {
+	rules := &URLRules{}
+	var err error
+	if rules.include, err = compilePatterns(include); err != nil {
+		return nil, err
+	}
+	if rules.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// compilePatterns turns prefixes, globs and regular expressions into regular expressions
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		var expr string
+		switch {
+		case strings.HasPrefix(pattern, "re:"):
+			expr = strings.TrimPrefix(pattern, "re:")
+		case strings.ContainsAny(pattern, "*?"):
+			// * matches any characters including / and ? a single character
+			expr = regexp.QuoteMeta(pattern)
+			expr = strings.Replace(expr, `\*`, ".*", -1)
+			expr = strings.Replace(expr, `\?`, ".", -1)
+			expr = "^" + expr + "$"
+		default:
+			expr = "^" + regexp.QuoteMeta(pattern)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Allowed returns true if the url matches an include pattern, or there are none, and no exclude pattern.
+// Nil rules allow every url
+func (self *URLRules) Allowed(link *url.URL) bool {
+	if self == nil {
+		return true
+	}
+	requestURI := link.RequestURI()
+	if len(self.include) > 0 && !matchesAnyPattern(self.include, requestURI) {
+		return false
+	}
+	return !matchesAnyPattern(self.exclude, requestURI)
+}
+
+func matchesAnyPattern(patterns []*regexp.Regexp, requestURI string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(requestURI) {
+			return true
+		}
+	}
+	return false
+}
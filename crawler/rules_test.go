@@ -0,0 +1,42 @@
+package crawler_test
+
+import (
+	"github.com/terencechow/crawl/crawler"
+	"net/url"
+	"testing"
+)
+
+func TestURLRules(t *testing.T) {
+	rules, err := crawler.ParseURLRules([]string{"/blog/", "/help/*/faq"}, []string{"/blog/drafts", "*.pdf", "re:[?&]print=1"})
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+
+	cases := map[string]bool{
+		"https://monzo.com/blog/":                true,
+		"https://monzo.com/blog/post":            true,
+		"https://monzo.com/blog/post?print=1":    false,
+		"https://monzo.com/blog/drafts/post":     false,
+		"https://monzo.com/blog/report.pdf":      false,
+		"https://monzo.com/help/cards/faq":       true,
+		"https://monzo.com/help/cards/faq/more":  false,
+		"https://monzo.com/about":                false,
+		"https://monzo.com/legal/blog/something": false,
+	}
+	for rawURL, expected := range cases {
+		link, _ := url.Parse(rawURL)
+		if result := rules.Allowed(link); result != expected {
+			t.Errorf("Expected %v for %s. Got %v", expected, rawURL, result)
+		}
+	}
+
+	// nil rules allow everything
+	var none *crawler.URLRules
+	if link, _ := url.Parse("https://monzo.com/about"); !none.Allowed(link) {
+		t.Error("Expected nil rules to allow every url")
+	}
+
+	if _, err := crawler.ParseURLRules(nil, []string{"re:("}); err == nil {
+		t.Error("Expected error with an invalid regular expression")
+	}
+}
@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"golang.org/x/net/publicsuffix"
+	"net/url"
+	"strings"
+)
+
+// Scope decides which links found on a page are part of the Sitemap and crawled
+type Scope interface {
+	InScope(pageURL *url.URL, link *url.URL) bool
+}
+
+// ScopeFunc adapts a function to a Scope
+type ScopeFunc func(pageURL *url.URL, link *url.URL) bool
+
+// InScope calls the function
+func (self ScopeFunc) InScope(pageURL *url.URL, link *url.URL) bool {
+	return self(pageURL, link)
+}
+
+// SameHostScope keeps links with the same host and scheme as the page they were found on
+var SameHostScope = ScopeFunc(func(pageURL *url.URL, link *url.URL) bool {
+	return siteHost(link) == siteHost(pageURL) && link.Scheme == pageURL.Scheme
+})
+
+// AnyScope keeps links in at least one of its scopes, ie a HostScope per seed
+type AnyScope []Scope
+
+// InScope returns true if one of the scopes keeps the link
+func (self AnyScope) InScope(pageURL *url.URL, link *url.URL) bool {
+	for _, scope := range self {
+		if scope.InScope(pageURL, link) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeMode is how the host of a link is compared to the host of the page it was found on
+type ScopeMode string
+
+const (
+	// SCOPE_HOST keeps links with the same host, ie www.monzo.com only
+	SCOPE_HOST ScopeMode = "host"
+	// SCOPE_SUBDOMAINS keeps links with the same host or one of its subdomains, ie monzo.com and blog.monzo.com
+	SCOPE_SUBDOMAINS ScopeMode = "subdomains"
+	// SCOPE_DOMAIN keeps links with the same registrable domain according to the public suffix list,
+	// ie www.monzo.com and help.monzo.com but not monzo.co.uk
+	SCOPE_DOMAIN ScopeMode = "domain"
+	// SCOPE_HOSTS keeps links whose host is one of an explicit list
+	SCOPE_HOSTS ScopeMode = "hosts"
+)
+
+// HostScope keeps links whose host matches Host according to its Mode
+type HostScope struct {
+	Mode ScopeMode
+
+	// Host is the host links are compared to, ie the host of the root url. The host of the page the link was found on if empty
+	Host string
+
+	// Hosts are the hosts in scope with SCOPE_HOSTS, ie www.monzo.com
+	Hosts []string
+
+	// AnyScheme treats http and https links as the same site, otherwise links must have the scheme of the page
+	AnyScheme bool
+}
+
+// InScope returns true if the link matches the scheme of the page and the host of the scope
+func (self *HostScope) InScope(pageURL *url.URL, link *url.URL) bool {
+	if self.AnyScheme {
+		if link.Scheme != "http" && link.Scheme != "https" {
+			return false
+		}
+	} else if link.Scheme != pageURL.Scheme {
+		return false
+	}
+
+	scopeURL := pageURL
+	if self.Host != "" {
+		scopeURL = &url.URL{Host: self.Host}
+	}
+	linkHost, scopeHost := strings.ToLower(link.Hostname()), strings.ToLower(scopeURL.Hostname())
+	switch self.Mode {
+	case SCOPE_SUBDOMAINS:
+		return linkHost == scopeHost || strings.HasSuffix(linkHost, "."+scopeHost)
+	case SCOPE_DOMAIN:
+		return registrableDomain(linkHost) == registrableDomain(scopeHost)
+	case SCOPE_HOSTS:
+		for _, host := range self.Hosts {
+			if host = strings.ToLower(host); host == siteHost(link) || host == linkHost {
+				return true
+			}
+		}
+		return false
+	default:
+		return siteHost(link) == siteHost(&url.URL{Scheme: link.Scheme, Host: scopeURL.Host})
+	}
+}
+
+// siteHost returns the lowercased host and port of a url, without the default port of its scheme,
+// ie https://Monzo.com:443 -> monzo.com
+func siteHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	switch port := u.Port(); {
+	case port == "80" && u.Scheme == "http", port == "443" && u.Scheme == "https":
+		return strings.TrimSuffix(host, ":"+port)
+	}
+	return host
+}
+
+// registrableDomain returns the public suffix of host plus one label, ie help.monzo.co.uk -> monzo.co.uk.
+// Hosts without one, like ip addresses or localhost, are returned as is
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
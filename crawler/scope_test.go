@@ -0,0 +1,86 @@
+package crawler_test
+
+import (
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestHostScope(t *testing.T) {
+	pageURL, _ := url.Parse("https://blog.monzo.com/post")
+	cases := []struct {
+		scope    *crawler.HostScope
+		link     string
+		expected bool
+	}{
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOST}, "https://blog.monzo.com/other", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOST}, "https://www.monzo.com/", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOST}, "http://blog.monzo.com/other", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOST, AnyScheme: true}, "http://blog.monzo.com/other", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOST, AnyScheme: true}, "ftp://blog.monzo.com/other", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_SUBDOMAINS, Host: "monzo.com"}, "https://monzo.com/", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_SUBDOMAINS, Host: "monzo.com"}, "https://help.monzo.com/", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_SUBDOMAINS, Host: "monzo.com"}, "https://notmonzo.com/", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_SUBDOMAINS, Host: "www.monzo.com"}, "https://help.monzo.com/", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_DOMAIN}, "https://www.monzo.com/", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_DOMAIN}, "https://monzo.co.uk/", false},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOSTS, Hosts: []string{"www.monzo.com", "Help.monzo.com"}}, "https://help.monzo.com/", true},
+		{&crawler.HostScope{Mode: crawler.SCOPE_HOSTS, Hosts: []string{"www.monzo.com", "help.monzo.com"}}, "https://blog.monzo.com/", false},
+	}
+	for _, c := range cases {
+		link, _ := url.Parse(c.link)
+		if result := c.scope.InScope(pageURL, link); result != c.expected {
+			t.Errorf("Expected %v for %s with %+v. Got %v", c.expected, c.link, c.scope, result)
+		}
+	}
+}
+
+func TestCrawlDomainScope(t *testing.T) {
+	fetcher := &fakeFetcher{pages: map[string]string{
+		"http://www.monzo.com/":     `<html><body><a href='/blog'>blog</a><a href='http://help.monzo.com/faq'>help</a><a href='http://monzo.co.uk'>uk</a></body></html>`,
+		"http://www.monzo.com/blog": `redirect:http://blog.monzo.com/`,
+		"http://blog.monzo.com/":    `<html><body></body></html>`,
+		"http://help.monzo.com/faq": `<html><body></body></html>`,
+		"http://monzo.co.uk":        `<html><body></body></html>`,
+	}}
+
+	blogURL, helpURL := "http://blog.monzo.com/", "http://help.monzo.com/faq"
+	scope := &crawler.HostScope{Mode: crawler.SCOPE_DOMAIN, Host: "www.monzo.com"}
+	sitemap := crawler.New(crawler.WithFetcher(fetcher), crawler.WithScope(scope)).Crawl("http://www.monzo.com")
+
+	expected := &Node{
+		URL: "http://www.monzo.com/",
+		Links: map[string]*Node{
+			blogURL: &Node{URL: blogURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+			helpURL: &Node{URL: helpURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+		},
+		Status: 200,
+	}
+	if !reflect.DeepEqual(sitemap, expected) {
+		t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+	}
+}
+
+func TestCrawlScopeNormalizedLinks(t *testing.T) {
+	fetcher := &fakeFetcher{pages: map[string]string{
+		"https://monzo.com/":      `<html><body><a href='https://Monzo.com/about'>about</a><a href='https://monzo.com:443/about'>about</a></body></html>`,
+		"https://monzo.com/about": `<html><body></body></html>`,
+	}}
+
+	aboutURL := "https://monzo.com/about"
+	expected := &Node{
+		URL: "https://monzo.com/",
+		Links: map[string]*Node{
+			aboutURL: &Node{URL: aboutURL, Links: map[string]*Node{}, Source: "a[href]", Status: 200},
+		},
+		Status: 200,
+	}
+	for _, scope := range []crawler.Scope{crawler.SameHostScope, &crawler.HostScope{Mode: crawler.SCOPE_HOST, Host: "monzo.com"}} {
+		sitemap := crawler.New(crawler.WithFetcher(fetcher), crawler.WithScope(scope)).Crawl("https://monzo.com")
+		if !reflect.DeepEqual(sitemap, expected) {
+			t.Error(fmt.Sprintf("Expected %v. Got %v", expected, sitemap))
+		}
+	}
+}
@@ -1,26 +1,195 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/terencechow/crawl/crawler"
 	"github.com/terencechow/crawl/parser"
 	"github.com/terencechow/crawl/writer"
-	"io/ioutil"
+	"io"
 	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
-func main() {
-	url, numHandlers, err := parser.GetCliArguments()
+// GRACE_TIMEOUT bounds how long in-flight fetches may take to finish once interrupted
+const GRACE_TIMEOUT = 10 * time.Second
+
+// stopReason describes why a crawl stopped
+func stopReason(err error) string {
+	if err == crawler.ErrStopped || err == context.Canceled {
+		return "crawl interrupted"
+	}
+	return err.Error()
+}
+
+// incompleteReason describes why a crawl did not finish and what it left unfetched
+func incompleteReason(err error, unfetched int) string {
+	return fmt.Sprintf("%s, %v discovered urls left unfetched", stopReason(err), unfetched)
+}
+
+// writeXML writes the sitemap as sitemaps.org XML files next to the output, split with a sitemap index if too large
+func writeXML(sitemap *crawler.Node, options writer.XMLOptions, args *parser.Arguments) {
+	index := writer.IndexOptions{
+		Name:    strings.TrimSuffix(filepath.Base(args.Output), ".gz"),
+		BaseURL: args.BaseURL,
+		Gzip:    args.Gzip,
+	}
+	dir := filepath.Dir(args.Output)
+	create := func(name string) (io.WriteCloser, error) {
+		return writer.CreateAtomicFile(filepath.Join(dir, name))
+	}
+	if err := writer.WriteXMLSiteMapFiles(create, sitemap, options, index); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeAtomic writes the output with write, leaving any previous output untouched if it fails
+func writeAtomic(output string, write func(w io.Writer) error) {
+	file, err := writer.CreateAtomicFile(output)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := write(file); err != nil {
+		file.Abort()
+		log.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	sitemap := crawler.CreateSiteMap(url, numHandlers)
-	prettified := writer.PrettifySiteMap(sitemap, 0)
+func main() {
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// write to file
-	data := []byte(prettified)
-	err = ioutil.WriteFile("./sitemap.txt", data, 0644)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rules, err := crawler.ParseURLRules(args.Include, args.Exclude)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// links are in scope if they are in the scope of one of the seeds
+	scope := crawler.AnyScope{}
+	for _, seed := range args.Seeds {
+		seedURL, err := url.Parse(seed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		scope = append(scope, &crawler.HostScope{
+			Mode:      crawler.ScopeMode(args.Scope),
+			Host:      seedURL.Host,
+			Hosts:     args.Hosts,
+			AnyScheme: args.AnyScheme,
+		})
+	}
+
+	retryPolicy := crawler.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = args.MaxAttempts
+	retryPolicy.MaxBackoff = args.MaxBackoff
+	retryPolicy.MaxRetryAfter = args.MaxRetryAfter
+
+	// jsonl records are written as pages are crawled, straight to the output so they can be consumed while crawling
+	var pages *writer.JSONLinesWriter
+	var onPage func(crawler.Page)
+	if args.Format == "jsonl" {
+		file, err := os.Create(args.Output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		pages = writer.NewJSONLinesWriter(file)
+		onPage = pages.WritePage
+	}
+
+	// -elements applies to every content type parsed as html
+	htmlExtractor := &crawler.HTMLExtractor{Elements: args.Elements}
+
+	c := crawler.New(
+		crawler.WithWorkers(args.Workers),
+		crawler.WithMaxDepth(args.MaxDepth),
+		crawler.WithMaxPages(args.MaxPages),
+		crawler.WithMaxDuration(args.MaxDuration),
+		crawler.WithUserAgent(args.UserAgent),
+		crawler.WithIgnoreRobots(args.IgnoreRobots),
+		crawler.WithCanonical(args.Canonical),
+		crawler.WithRobotsMeta(args.RobotsMeta),
+		crawler.WithNormalizer(args.Normalizer),
+		crawler.WithScope(scope),
+		crawler.WithURLRules(rules),
+		crawler.WithListExcluded(args.ListExcluded),
+		crawler.WithRateLimit(args.Rate, args.Burst),
+		crawler.WithRetryPolicy(retryPolicy),
+		crawler.WithExtractor("text/html", htmlExtractor),
+		crawler.WithExtractor("application/xhtml+xml", htmlExtractor),
+		crawler.WithOnPage(onPage),
+	)
+
+	// on SIGINT / SIGTERM stop crawling new urls and let in-flight fetches finish
+	// a second signal or the grace timeout aborts them
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Printf("Interrupted, waiting up to %v for in-flight requests...\n", GRACE_TIMEOUT)
+		c.Stop()
+		select {
+		case <-signals:
+		case <-time.After(GRACE_TIMEOUT):
+		}
+		cancel()
+	}()
+
+	sitemap, err := c.CrawlSeeds(ctx, args.Seeds)
+	incomplete := ""
+	if err != nil {
+		incomplete = incompleteReason(err, c.Unfetched())
+	}
+	switch args.Format {
+	case "xml":
+		options := writer.XMLOptions{LastMod: args.LastMod, ChangeFreq: args.ChangeFreq, Priority: args.Priority, Incomplete: incomplete}
+		writeXML(sitemap, options, args)
+	case "json":
+		writeAtomic(args.Output, func(w io.Writer) error {
+			if incomplete != "" {
+				return writer.WriteIncompleteJSON(w, sitemap, incomplete)
+			}
+			return writer.WriteJSON(w, sitemap)
+		})
+	case "dot", "mermaid":
+		options := writer.GraphOptions{
+			Mode:       writer.GraphMode(args.GraphMode),
+			MaxDepth:   args.GraphDepth,
+			Collapse:   args.Collapse,
+			Incomplete: incomplete,
+		}
+		writeAtomic(args.Output, func(w io.Writer) error {
+			if args.Format == "dot" {
+				return writer.WriteDOT(w, sitemap, options)
+			}
+			return writer.WriteMermaid(w, sitemap, options)
+		})
+	case "jsonl":
+		if err != nil {
+			pages.WriteIncomplete(stopReason(err), c.Unfetched())
+		}
+		if err := pages.Err(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		writeAtomic(args.Output, func(w io.Writer) error {
+			if incomplete != "" {
+				return writer.WriteIncompleteSiteMap(w, sitemap, incomplete)
+			}
+			return writer.WriteSiteMap(w, sitemap)
+		})
+	}
 }
@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Normalizer rewrites urls to a canonical form so a page found with different urls is crawled once.
+// Each rule can be switched off. Fragments are always removed, query strings unless KeepQuery is set,
+// and an empty path is always the root path /
+type Normalizer struct {
+	// LowercaseHost lowercases the scheme and host, ie HTTPS://Monzo.com -> https://monzo.com
+	LowercaseHost bool
+
+	// RemoveDefaultPort drops :80 from http and :443 from https urls
+	RemoveDefaultPort bool
+
+	// RemoveDotSegments resolves . and .. path segments, ie /a/./b/../c -> /a/c
+	RemoveDotSegments bool
+
+	// NormalizeEscapes uppercases percent-encodings and decodes unreserved characters, ie %7euser%2f -> ~user%2F
+	NormalizeEscapes bool
+
+	// RemoveTrailingSlash removes the trailing slash of paths so /about/ and /about are the same url
+	RemoveTrailingSlash bool
+
+	// RemoveUserinfo drops the user and password of urls
+	RemoveUserinfo bool
+
+	// KeepQuery keeps query strings with their parameters sorted, ie for paginated listings like ?page=2
+	KeepQuery bool
+
+	// QueryAllow keeps only the parameters matching one of its patterns, ie page. Every parameter is kept if empty
+	QueryAllow []string
+
+	// QueryDeny drops the parameters matching one of its patterns, ie utm_* or sessionid
+	QueryDeny []string
+}
+
+// DefaultNormalizer applies every rule that doesn't change which resource a url points to,
+// keeping trailing slashes and userinfo
+var DefaultNormalizer = Normalizer{
+	LowercaseHost:     true,
+	RemoveDefaultPort: true,
+	RemoveDotSegments: true,
+	NormalizeEscapes:  true,
+}
+
+// NORMALIZE_RULES are the names of the rules of a Normalizer, as passed to -normalize
+var NORMALIZE_RULES = []string{"case", "port", "dots", "escapes", "slash", "userinfo"}
+
+// NormalizerWithRules returns a Normalizer applying only the named NORMALIZE_RULES
+func NormalizerWithRules(rules []string) (Normalizer, error) {
+	normalizer := Normalizer{}
+	for _, rule := range rules {
+		switch strings.ToLower(strings.TrimSpace(rule)) {
+		case "case":
+			normalizer.LowercaseHost = true
+		case "port":
+			normalizer.RemoveDefaultPort = true
+		case "dots":
+			normalizer.RemoveDotSegments = true
+		case "escapes":
+			normalizer.NormalizeEscapes = true
+		case "slash":
+			normalizer.RemoveTrailingSlash = true
+		case "userinfo":
+			normalizer.RemoveUserinfo = true
+		case "":
+		default:
+			return normalizer, fmt.Errorf("normalize rules must be some of %s, got %q", strings.Join(NORMALIZE_RULES, ","), rule)
+		}
+	}
+	return normalizer, nil
+}
+
+// default port of each scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns the normalized url. Urls that can't be parsed only have their query string and fragment removed
+func (self Normalizer) Normalize(rawURL string) string {
+	stripped := "?#"
+	if self.KeepQuery {
+		stripped = "#"
+	}
+	if idx := strings.IndexAny(rawURL, stripped); idx != -1 {
+		rawURL = rawURL[:idx]
+	}
+	currentURL, err := url.Parse(rawURL)
+	if err != nil || currentURL.Opaque != "" {
+		return rawURL
+	}
+
+	if self.LowercaseHost {
+		currentURL.Scheme = strings.ToLower(currentURL.Scheme)
+		currentURL.Host = strings.ToLower(currentURL.Host)
+	}
+	if port := currentURL.Port(); self.RemoveDefaultPort && port != "" && defaultPorts[strings.ToLower(currentURL.Scheme)] == port {
+		currentURL.Host = strings.TrimSuffix(currentURL.Host, ":"+port)
+	}
+	if self.RemoveUserinfo {
+		currentURL.User = nil
+	}
+
+	escapedPath := currentURL.EscapedPath()
+	if self.NormalizeEscapes {
+		escapedPath = normalizeEscapes(escapedPath)
+	}
+	if self.RemoveDotSegments {
+		escapedPath = removeDotSegments(escapedPath)
+	}
+	// an empty path is the root of the host, RFC 3986 section 6.2.3
+	if escapedPath == "" && currentURL.Host != "" {
+		escapedPath = "/"
+	}
+	if self.RemoveTrailingSlash {
+		escapedPath = strings.TrimSuffix(escapedPath, "/")
+	}
+	unescapedPath, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return currentURL.String()
+	}
+	currentURL.Path, currentURL.RawPath = unescapedPath, escapedPath
+
+	if currentURL.RawQuery != "" || currentURL.ForceQuery {
+		currentURL.RawQuery = self.normalizeQuery(currentURL.RawQuery)
+		currentURL.ForceQuery = false
+	}
+
+	return currentURL.String()
+}
+
+// normalizeQuery sorts the parameters of a query string by name, keeping only the ones allowed and not denied
+func (self Normalizer) normalizeQuery(rawQuery string) string {
+	// invalid parameters are dropped by ParseQuery
+	values, _ := url.ParseQuery(rawQuery)
+	for name := range values {
+		if (len(self.QueryAllow) > 0 && !matchesAny(self.QueryAllow, name)) || matchesAny(self.QueryDeny, name) {
+			delete(values, name)
+		}
+	}
+	return values.Encode()
+}
+
+// matchesAny returns true if name matches one of the glob patterns, ie utm_*
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes uppercases the hex digits of percent-encodings and decodes the ones of unreserved characters
+func normalizeEscapes(escaped string) string {
+	var result strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' || i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			result.WriteByte(escaped[i])
+			continue
+		}
+		hex := strings.ToUpper(escaped[i+1 : i+3])
+		if char := unhex(hex[0])<<4 | unhex(hex[1]); isUnreserved(char) {
+			result.WriteByte(char)
+		} else {
+			result.WriteString("%" + hex)
+		}
+		i += 2
+	}
+	return result.String()
+}
+
+// removeDotSegments resolves the . and .. segments of an absolute path as in RFC 3986 section 5.2.4
+func removeDotSegments(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	output := []string{}
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+		default:
+			output = append(output, segment)
+			continue
+		}
+		// a path ending with a dot segment is a directory
+		if last {
+			output = append(output, "")
+		}
+	}
+	return strings.Join(output, "/")
+}
+
+// unreserved characters of RFC 3986 never need to be percent-encoded
+func isUnreserved(char byte) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' ||
+		char == '-' || char == '.' || char == '_' || char == '~'
+}
+
+func isHex(char byte) bool {
+	return '0' <= char && char <= '9' || 'a' <= char && char <= 'f' || 'A' <= char && char <= 'F'
+}
+
+// unhex returns the value of an uppercase hex digit
+func unhex(char byte) byte {
+	if char >= 'A' {
+		return char - 'A' + 10
+	}
+	return char - '0'
+}
@@ -1,37 +1,299 @@
 package parser
 
 import (
+	"bufio"
 	"errors"
 	"flag"
+	"fmt"
 	"net/url"
+	"os"
+	"path"
 	"strings"
+	"time"
 )
 
-// NormalizeURL removes query strings and fragments from the url
+// NormalizeURL normalizes the url with the DefaultNormalizer, removing query strings and fragments
 func NormalizeURL(currentURL string) string {
-	idx := strings.IndexAny(currentURL, "?#")
-	if idx != -1 {
-		currentURL = currentURL[:idx]
+	return DefaultNormalizer.Normalize(currentURL)
+}
+
+// contains returns true if value is in values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList returns the non empty values of a comma separated list
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// readSeeds returns the urls of a seeds file, one per line. Empty lines and lines starting with # are ignored
+func readSeeds(seedsFile string) ([]string, error) {
+	file, err := os.Open(seedsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	seeds := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			seeds = append(seeds, line)
+		}
 	}
-	return currentURL
+	return seeds, scanner.Err()
+}
+
+// Arguments holds the values passed into the cli
+type Arguments struct {
+	URL           string
+	Seeds         []string
+	Workers       int
+	MaxDepth      int
+	MaxPages      int
+	MaxDuration   time.Duration
+	UserAgent     string
+	IgnoreRobots  bool
+	Rate          float64
+	Burst         int
+	MaxAttempts   int
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+	Elements      []string
+	Canonical     bool
+	RobotsMeta    bool
+	Normalizer    Normalizer
+	Include       []string
+	Exclude       []string
+	ListExcluded  bool
+	Scope         string
+	Hosts         []string
+	AnyScheme     bool
+	Format        string
+	Output        string
+	LastMod       time.Time
+	ChangeFreq    string
+	Priority      float64
+	Gzip          bool
+	BaseURL       string
+	GraphMode     string
+	GraphDepth    int
+	Collapse      []string
+}
+
+// FORMATS are the formats the sitemap can be written in
+var FORMATS = []string{"text", "xml", "json", "jsonl", "dot", "mermaid"}
+
+// FORMAT_EXTENSIONS are the extensions of the default output of each format
+var FORMAT_EXTENSIONS = map[string]string{"text": "txt", "xml": "xml", "json": "json", "jsonl": "jsonl", "dot": "dot", "mermaid": "mmd"}
+
+// GRAPH_MODES are the links drawn by the dot and mermaid formats
+var GRAPH_MODES = []string{"tree", "links"}
+
+// CHANGE_FREQUENCIES are the values of the changefreq tag of XML sitemaps
+var CHANGE_FREQUENCIES = []string{"always", "hourly", "daily", "weekly", "monthly", "yearly", "never"}
+
+// SCOPE_MODES are how the host of links is compared to the host of the url
+var SCOPE_MODES = []string{"host", "subdomains", "domain", "hosts"}
+
+// listFlag is a flag that can be repeated, collecting every value
+type listFlag []string
+
+func (self *listFlag) String() string {
+	return strings.Join(*self, ",")
 }
 
-// GetCliArguments grabs the url and number of workers passed into the cli
-func GetCliArguments() (string, int, error) {
+func (self *listFlag) Set(value string) error {
+	*self = append(*self, value)
+	return nil
+}
+
+// HTML_ELEMENTS are the elements links can be extracted from
+var HTML_ELEMENTS = []string{"a", "area", "link", "iframe", "frame", "form"}
 
-	var rawurl string
-	var workers int
-	flag.StringVar(&rawurl, "url", "", "The URL to crawl")
-	flag.IntVar(&workers, "workers", 4, "Number of goroutines to spawn concurrently")
+// GetCliArguments grabs the urls, number of workers and crawl limits passed into the cli
+func GetCliArguments() (*Arguments, error) {
+
+	var rawurls listFlag
+	var seedsFile, elements, normalize, queryAllow, queryDeny, hosts, lastMod, collapse string
+	var keepQuery bool
+	args := &Arguments{}
+	flag.Var(&rawurls, "url", "The URL to crawl. Repeat it to crawl several seeds")
+	flag.StringVar(&seedsFile, "seeds-file", "", "File listing more URLs to crawl, one per line")
+	flag.IntVar(&args.Workers, "workers", 4, "Number of goroutines to spawn concurrently")
+	flag.IntVar(&args.MaxDepth, "max-depth", 0, "Maximum number of links followed from the URL, 0 for no limit")
+	flag.IntVar(&args.MaxPages, "max-pages", 0, "Maximum number of pages fetched, 0 for no limit")
+	flag.DurationVar(&args.MaxDuration, "max-duration", 0, "Maximum duration of the crawl ie 30m, 0 for no limit")
+	flag.StringVar(&args.UserAgent, "user-agent", "crawl", "User-Agent sent with requests and matched against robots.txt")
+	flag.BoolVar(&args.IgnoreRobots, "ignore-robots", false, "Crawl urls disallowed by robots.txt")
+	flag.Float64Var(&args.Rate, "rate", 5, "Maximum requests per second per host, 0 for no limit. Crawl-delay in robots.txt may lower it")
+	flag.IntVar(&args.Burst, "burst", 1, "Number of requests per host allowed at once before the rate applies")
+	flag.IntVar(&args.MaxAttempts, "max-attempts", 6, "Maximum number of requests per url when retrying server errors, 1 to disable retries")
+	flag.DurationVar(&args.MaxBackoff, "max-backoff", 16*time.Second, "Maximum delay between retries")
+	flag.DurationVar(&args.MaxRetryAfter, "max-retry-after", 5*time.Minute, "Longest Retry-After header waited for, urls asking to wait longer are not retried")
+	flag.BoolVar(&args.RobotsMeta, "robots-meta", false, "Honor rel=nofollow, <meta name=\"robots\"> and X-Robots-Tag")
+	flag.BoolVar(&args.Canonical, "canonical", false, "Collapse pages onto the url of their <link rel=\"canonical\">")
+	flag.StringVar(&normalize, "normalize", "case,port,dots,escapes", "Comma separated rules normalizing urls: "+strings.Join(NORMALIZE_RULES, ","))
+	flag.BoolVar(&keepQuery, "keep-query", false, "Keep query strings of urls, sorting their parameters")
+	flag.StringVar(&queryAllow, "query-allow", "", "Comma separated query parameters kept with -keep-query, globs like page* allowed. All if empty")
+	flag.StringVar(&queryDeny, "query-deny", "", "Comma separated query parameters dropped with -keep-query, globs like utm_* allowed")
+	flag.Var((*listFlag)(&args.Include), "include", "Only crawl urls whose path starts with this prefix, matches this glob or re:regexp. Repeatable")
+	flag.Var((*listFlag)(&args.Exclude), "exclude", "Skip urls whose path starts with this prefix, matches this glob or re:regexp. Repeatable")
+	flag.BoolVar(&args.ListExcluded, "list-excluded", false, "List urls skipped by -include and -exclude in the sitemap as out of scope")
+	flag.StringVar(&args.Scope, "scope", "host", "Links crawled: host for the host of the url, subdomains to add its subdomains, domain for its registrable domain or hosts for -hosts")
+	flag.StringVar(&hosts, "hosts", "", "Comma separated hosts crawled with -scope=hosts, the host of the url is always crawled")
+	flag.BoolVar(&args.AnyScheme, "any-scheme", false, "Treat http and https links as the same site")
+	flag.StringVar(&args.Format, "format", "text", "Format of the sitemap: "+strings.Join(FORMATS, ","))
+	flag.StringVar(&args.Output, "output", "", "File the sitemap is written to, sitemap. followed by the extension of the format by default")
+	flag.StringVar(&lastMod, "lastmod", "", "lastmod date of every url of an XML sitemap, ie 2006-01-02")
+	flag.StringVar(&args.ChangeFreq, "changefreq", "", "changefreq of every url of an XML sitemap: "+strings.Join(CHANGE_FREQUENCIES, ","))
+	flag.Float64Var(&args.Priority, "priority", 0, "priority of every url of an XML sitemap between 0 and 1, 0 to omit it")
+	flag.BoolVar(&args.Gzip, "gzip", false, "Gzip XML sitemaps, adding .gz to their names")
+	flag.StringVar(&args.BaseURL, "base-url", "", "Public url XML sitemaps split in several files are served from, the root of the url by default")
+	flag.StringVar(&args.GraphMode, "graph-mode", "tree", "Links drawn by the dot and mermaid formats: tree for the page each url was crawled from or links for every link")
+	flag.IntVar(&args.GraphDepth, "graph-depth", 0, "Leave urls more links away from the root out of dot and mermaid graphs, 0 for no limit")
+	flag.StringVar(&collapse, "collapse", "", "Comma separated path prefixes drawn as a single node in dot and mermaid graphs, ie /blog/")
+	flag.StringVar(&elements, "elements", "a", "Comma separated elements to extract links from: "+strings.Join(HTML_ELEMENTS, ","))
 	flag.Parse()
 
-	if workers < 1 || workers > 10 {
-		return "", -1, errors.New("workers must be less than 10 and greater than 0")
+	if args.Workers < 1 || args.Workers > 10 {
+		return nil, errors.New("workers must be less than 10 and greater than 0")
+	}
+	if args.MaxDepth < 0 {
+		return nil, errors.New("max-depth must be 0 or greater")
+	}
+	if args.MaxPages < 0 {
+		return nil, errors.New("max-pages must be 0 or greater")
+	}
+	if args.MaxDuration < 0 {
+		return nil, errors.New("max-duration must be 0 or greater")
+	}
+	if args.Rate < 0 {
+		return nil, errors.New("rate must be 0 or greater")
 	}
-	currentURL, err := url.ParseRequestURI(NormalizeURL(rawurl))
+	if args.Burst < 1 {
+		return nil, errors.New("burst must be greater than 0")
+	}
+	if args.MaxAttempts < 1 {
+		return nil, errors.New("max-attempts must be greater than 0")
+	}
+	if args.MaxBackoff < 0 {
+		return nil, errors.New("max-backoff must be 0 or greater")
+	}
+	if args.MaxRetryAfter < 0 {
+		return nil, errors.New("max-retry-after must be 0 or greater")
+	}
+	for _, element := range strings.Split(elements, ",") {
+		element = strings.ToLower(strings.TrimSpace(element))
+		if !contains(HTML_ELEMENTS, element) {
+			return nil, fmt.Errorf("elements must be some of %s, got %q", strings.Join(HTML_ELEMENTS, ","), element)
+		}
+		args.Elements = append(args.Elements, element)
+	}
+	normalizer, err := NormalizerWithRules(strings.Split(normalize, ","))
 	if err != nil {
-		return "", -1, err
+		return nil, err
+	}
+	normalizer.KeepQuery = keepQuery
+	normalizer.QueryAllow = splitList(queryAllow)
+	normalizer.QueryDeny = splitList(queryDeny)
+	if (len(normalizer.QueryAllow) > 0 || len(normalizer.QueryDeny) > 0) && !keepQuery {
+		return nil, errors.New("query-allow and query-deny require keep-query")
+	}
+	for _, pattern := range append(normalizer.QueryAllow, normalizer.QueryDeny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid query parameter pattern %q", pattern)
+		}
+	}
+	args.Normalizer = normalizer
+
+	if !contains(FORMATS, args.Format) {
+		return nil, fmt.Errorf("format must be one of %s, got %q", strings.Join(FORMATS, ","), args.Format)
+	}
+	if args.Output == "" {
+		args.Output = "./sitemap." + FORMAT_EXTENSIONS[args.Format]
+	}
+	if lastMod != "" {
+		if args.LastMod, err = time.Parse("2006-01-02", lastMod); err != nil {
+			return nil, errors.New("lastmod must be a date like 2006-01-02")
+		}
+	}
+	if args.ChangeFreq != "" && !contains(CHANGE_FREQUENCIES, args.ChangeFreq) {
+		return nil, fmt.Errorf("changefreq must be one of %s, got %q", strings.Join(CHANGE_FREQUENCIES, ","), args.ChangeFreq)
+	}
+	if args.Priority < 0 || args.Priority > 1 {
+		return nil, errors.New("priority must be between 0 and 1")
+	}
+
+	if seedsFile != "" {
+		seeds, err := readSeeds(seedsFile)
+		if err != nil {
+			return nil, err
+		}
+		rawurls = append(rawurls, seeds...)
+	}
+	if len(rawurls) == 0 {
+		return nil, errors.New("url or seeds-file is required")
+	}
+	seedHosts := []string{}
+	for _, rawurl := range rawurls {
+		currentURL, err := url.ParseRequestURI(normalizer.Normalize(rawurl))
+		if err != nil {
+			return nil, err
+		}
+		args.Seeds = append(args.Seeds, currentURL.String())
+		if !contains(seedHosts, currentURL.Host) {
+			seedHosts = append(seedHosts, currentURL.Host)
+		}
+	}
+	args.URL = args.Seeds[0]
+
+	if args.BaseURL == "" {
+		rootURL, _ := url.Parse(args.URL)
+		args.BaseURL = rootURL.Scheme + "://" + rootURL.Host + "/"
+	} else if _, err := url.ParseRequestURI(args.BaseURL); err != nil {
+		return nil, errors.New("base-url must be an absolute url")
+	}
+	if args.Gzip && args.Format != "xml" {
+		return nil, errors.New("gzip requires format=xml")
+	}
+	if !contains(GRAPH_MODES, args.GraphMode) {
+		return nil, fmt.Errorf("graph-mode must be one of %s, got %q", strings.Join(GRAPH_MODES, ","), args.GraphMode)
+	}
+	if args.GraphDepth < 0 {
+		return nil, errors.New("graph-depth must be 0 or greater")
+	}
+	args.Collapse = splitList(collapse)
+	for _, prefix := range args.Collapse {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("collapse prefixes must start with /, got %q", prefix)
+		}
+	}
+
+	if !contains(SCOPE_MODES, args.Scope) {
+		return nil, fmt.Errorf("scope must be one of %s, got %q", strings.Join(SCOPE_MODES, ","), args.Scope)
+	}
+	args.Hosts = splitList(hosts)
+	if len(args.Hosts) > 0 && args.Scope != "hosts" {
+		return nil, errors.New("hosts requires scope=hosts")
+	}
+	for _, host := range seedHosts {
+		if args.Scope == "hosts" && !contains(args.Hosts, host) {
+			args.Hosts = append(args.Hosts, host)
+		}
 	}
 
-	return currentURL.String(), workers, nil
+	return args, nil
 }
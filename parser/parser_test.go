@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"github.com/terencechow/crawl/parser"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func resetFlagsForTesting() {
@@ -13,7 +16,7 @@ func resetFlagsForTesting() {
 }
 
 func TestNoArguments(t *testing.T) {
-	_, _, err := parser.GetCliArguments()
+	_, err := parser.GetCliArguments()
 	if err == nil {
 		t.Error("Expected error when no url provided")
 	}
@@ -30,7 +33,7 @@ func TestInvalidURLArgument(t *testing.T) {
 		defer func() { os.Args = oldArgs }()
 		os.Args = []string{oldArgs[0], fmt.Sprintf("-url=%s", invalidURL), "-workers=3"}
 
-		_, _, err := parser.GetCliArguments()
+		_, err := parser.GetCliArguments()
 		if err == nil {
 			t.Error("Expected error when invalid url provided")
 		}
@@ -49,7 +52,7 @@ func TestInvalidWorkersArgument(t *testing.T) {
 		defer func() { os.Args = oldArgs }()
 		os.Args = []string{oldArgs[0], "-url=https://www.google.com", fmt.Sprintf("-workers=%v", invalidWorker)}
 
-		_, _, err := parser.GetCliArguments()
+		_, err := parser.GetCliArguments()
 		if err == nil {
 			t.Error("Expected error when value provided for workers")
 		}
@@ -70,15 +73,361 @@ func TestValidURLArgument(t *testing.T) {
 		defer func() { os.Args = oldArgs }()
 		os.Args = []string{oldArgs[0], fmt.Sprintf("-url=%s", validURL), "-workers=3"}
 
-		url, workers, err := parser.GetCliArguments()
+		args, err := parser.GetCliArguments()
 		if err != nil {
 			t.Error("Expected error to be nil with a valid argument", err)
+			continue
 		}
-		if url != "https://www.google.com" {
-			t.Error("Expected url to be https://www.google.com")
+		if args.URL != "https://www.google.com/" {
+			t.Error("Expected url to be https://www.google.com/")
 		}
-		if workers != 3 {
+		if args.Workers != 3 {
 			t.Error("Expected number of workers to be 3")
 		}
 	}
 }
+
+func TestMaxDepthArgument(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com"}
+	args, err := parser.GetCliArguments()
+	if err != nil || args.MaxDepth != 0 {
+		t.Error("Expected max depth to default to 0", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-max-depth=3"}
+	args, err = parser.GetCliArguments()
+	if err != nil || args.MaxDepth != 3 {
+		t.Error("Expected max depth to be 3", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-max-depth=-1"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error when max depth is negative")
+	}
+}
+
+func TestBudgetArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-max-pages=100", "-max-duration=30m"}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected error to be nil with valid budgets", err)
+	} else if args.MaxPages != 100 || args.MaxDuration != 30*time.Minute {
+		t.Error(fmt.Sprintf("Expected budgets of 100 pages and 30m. Got %v and %v", args.MaxPages, args.MaxDuration))
+	}
+
+	invalidBudgets := []string{"-max-pages=-1", "-max-duration=-1s"}
+	for _, invalidBudget := range invalidBudgets {
+		resetFlagsForTesting()
+		os.Args = []string{oldArgs[0], "-url=https://www.google.com", invalidBudget}
+		_, err := parser.GetCliArguments()
+		if err == nil {
+			t.Error("Expected error with negative budget", invalidBudget)
+		}
+	}
+}
+
+func TestRateLimitArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-rate=2.5", "-burst=3"}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected error to be nil with a valid rate limit", err)
+	} else if args.Rate != 2.5 || args.Burst != 3 {
+		t.Error(fmt.Sprintf("Expected rate of 2.5 and burst of 3. Got %v and %v", args.Rate, args.Burst))
+	}
+
+	invalidLimits := []string{"-rate=-1", "-burst=0"}
+	for _, invalidLimit := range invalidLimits {
+		resetFlagsForTesting()
+		os.Args = []string{oldArgs[0], "-url=https://www.google.com", invalidLimit}
+		_, err := parser.GetCliArguments()
+		if err == nil {
+			t.Error("Expected error with invalid rate limit", invalidLimit)
+		}
+	}
+}
+
+func TestRetryArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-max-attempts=3", "-max-backoff=1m", "-max-retry-after=10m"}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected error to be nil with a valid retry policy", err)
+	} else if args.MaxAttempts != 3 || args.MaxBackoff != time.Minute || args.MaxRetryAfter != 10*time.Minute {
+		t.Error(fmt.Sprintf("Expected 3 attempts, a max backoff of 1m and a max retry after of 10m. Got %v, %v and %v", args.MaxAttempts, args.MaxBackoff, args.MaxRetryAfter))
+	}
+
+	invalidRetries := []string{"-max-attempts=0", "-max-backoff=-1s", "-max-retry-after=-1s"}
+	for _, invalidRetry := range invalidRetries {
+		resetFlagsForTesting()
+		os.Args = []string{oldArgs[0], "-url=https://www.google.com", invalidRetry}
+		_, err := parser.GetCliArguments()
+		if err == nil {
+			t.Error("Expected error with invalid retry policy", invalidRetry)
+		}
+	}
+}
+
+func TestElementsArgument(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com"}
+	args, err := parser.GetCliArguments()
+	if err != nil || !reflect.DeepEqual(args.Elements, []string{"a"}) {
+		t.Error("Expected elements to default to a", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-elements=a, IFRAME,form"}
+	args, err = parser.GetCliArguments()
+	if err != nil || !reflect.DeepEqual(args.Elements, []string{"a", "iframe", "form"}) {
+		t.Error("Expected elements to be a, iframe & form", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-elements=a,img"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with an unknown element")
+	}
+}
+
+func TestNormalizeArgument(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=HTTPS://Www.Google.com:443/"}
+	args, err := parser.GetCliArguments()
+	if err != nil || !reflect.DeepEqual(args.Normalizer, parser.DefaultNormalizer) || args.URL != "https://www.google.com/" {
+		t.Error("Expected the default normalizer", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com/", "-normalize=slash"}
+	args, err = parser.GetCliArguments()
+	if err != nil || !reflect.DeepEqual(args.Normalizer, parser.Normalizer{RemoveTrailingSlash: true}) || args.URL != "https://www.google.com" {
+		t.Error("Expected only trailing slashes to be removed", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-normalize=case,query"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with an unknown rule")
+	}
+}
+
+func TestQueryArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com/search?q=go&utm_source=x", "-keep-query", "-query-deny=utm_*, sessionid"}
+	args, err := parser.GetCliArguments()
+	if err != nil || !args.Normalizer.KeepQuery || !reflect.DeepEqual(args.Normalizer.QueryDeny, []string{"utm_*", "sessionid"}) {
+		t.Error("Expected query strings to be kept without utm_* & sessionid", err)
+	}
+	if args.URL != "https://www.google.com/search?q=go" {
+		t.Error("Expected the url to keep its query string. Got", args.URL)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-query-allow=page"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with query-allow but no keep-query")
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-keep-query", "-query-deny=utm_["}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with an invalid pattern")
+	}
+}
+
+func TestIncludeExcludeArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-include=/blog/", "-exclude=/cdn-cgi/", "-exclude=re:/legal/", "-list-excluded"}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	if !reflect.DeepEqual(args.Include, []string{"/blog/"}) || !reflect.DeepEqual(args.Exclude, []string{"/cdn-cgi/", "re:/legal/"}) || !args.ListExcluded {
+		t.Error(fmt.Sprintf("Expected include & exclude rules to be collected. Got %v %v", args.Include, args.Exclude))
+	}
+}
+
+func TestScopeArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.monzo.com", "-scope=hosts", "-hosts=blog.monzo.com, help.monzo.com", "-any-scheme"}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	if args.Scope != "hosts" || !args.AnyScheme || !reflect.DeepEqual(args.Hosts, []string{"blog.monzo.com", "help.monzo.com", "www.monzo.com"}) {
+		t.Error(fmt.Sprintf("Expected hosts scope including the url host. Got %v %v", args.Scope, args.Hosts))
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.monzo.com", "-scope=world"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with an unknown scope")
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.monzo.com", "-hosts=blog.monzo.com"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with hosts but no hosts scope")
+	}
+}
+
+func TestSeedsArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	seedsFile, err := ioutil.TempFile("", "seeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(seedsFile.Name())
+	seedsFile.WriteString("# landing pages\nhttps://www.google.com/landing\n\nhttps://maps.google.com\n")
+	seedsFile.Close()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-url=https://www.google.com/about", "-seeds-file=" + seedsFile.Name()}
+	args, err := parser.GetCliArguments()
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	expected := []string{"https://www.google.com/", "https://www.google.com/about", "https://www.google.com/landing", "https://maps.google.com/"}
+	if !reflect.DeepEqual(args.Seeds, expected) || args.URL != expected[0] {
+		t.Error(fmt.Sprintf("Expected seeds %v. Got %v", expected, args.Seeds))
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-seeds-file=" + seedsFile.Name() + ".missing"}
+	_, err = parser.GetCliArguments()
+	if err == nil {
+		t.Error("Expected error with a missing seeds file")
+	}
+}
+
+func TestFormatArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com"}
+	args, err := parser.GetCliArguments()
+	if err != nil || args.Format != "text" || args.Output != "./sitemap.txt" {
+		t.Error("Expected text format written to sitemap.txt by default", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=xml", "-lastmod=2018-05-01", "-changefreq=daily", "-priority=0.5"}
+	args, err = parser.GetCliArguments()
+	if err != nil || args.Output != "./sitemap.xml" || args.LastMod != time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC) || args.ChangeFreq != "daily" || args.Priority != 0.5 {
+		t.Error("Expected xml format written to sitemap.xml", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=jsonl"}
+	args, err = parser.GetCliArguments()
+	if err != nil || args.Output != "./sitemap.jsonl" {
+		t.Error("Expected jsonl format written to sitemap.jsonl", err)
+	}
+
+	invalid := []string{"-format=csv", "-lastmod=yesterday", "-changefreq=sometimes", "-priority=2"}
+	for _, arg := range invalid {
+		resetFlagsForTesting()
+		os.Args = []string{oldArgs[0], "-url=https://www.google.com", arg}
+		if _, err := parser.GetCliArguments(); err == nil {
+			t.Error("Expected error with", arg)
+		}
+	}
+}
+
+func TestSitemapIndexArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com/maps", "-format=xml", "-gzip"}
+	args, err := parser.GetCliArguments()
+	if err != nil || !args.Gzip || args.BaseURL != "https://www.google.com/" {
+		t.Error("Expected gzip with the root of the url as base url", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=xml", "-base-url=https://cdn.google.com/sitemaps/"}
+	args, err = parser.GetCliArguments()
+	if err != nil || args.BaseURL != "https://cdn.google.com/sitemaps/" {
+		t.Error("Expected the base url to be set", err)
+	}
+
+	invalid := [][]string{{"-gzip"}, {"-format=xml", "-base-url=sitemaps"}}
+	for _, extra := range invalid {
+		resetFlagsForTesting()
+		os.Args = append([]string{oldArgs[0], "-url=https://www.google.com"}, extra...)
+		if _, err := parser.GetCliArguments(); err == nil {
+			t.Error("Expected error with", extra)
+		}
+	}
+}
+
+func TestGraphArguments(t *testing.T) {
+	resetFlagsForTesting()
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=mermaid"}
+	args, err := parser.GetCliArguments()
+	if err != nil || args.Output != "./sitemap.mmd" || args.GraphMode != "tree" || args.GraphDepth != 0 || args.Collapse != nil {
+		t.Error("Expected the tree written to sitemap.mmd by default", err)
+	}
+
+	resetFlagsForTesting()
+	os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=dot", "-graph-mode=links", "-graph-depth=2", "-collapse=/blog/,/help/"}
+	args, err = parser.GetCliArguments()
+	if err != nil || args.Output != "./sitemap.dot" || args.GraphMode != "links" || args.GraphDepth != 2 || !reflect.DeepEqual(args.Collapse, []string{"/blog/", "/help/"}) {
+		t.Error("Expected the link graph to depth 2 collapsing /blog/ and /help/", err)
+	}
+
+	invalid := []string{"-graph-mode=forest", "-graph-depth=-1", "-collapse=blog"}
+	for _, arg := range invalid {
+		resetFlagsForTesting()
+		os.Args = []string{oldArgs[0], "-url=https://www.google.com", "-format=dot", arg}
+		if _, err := parser.GetCliArguments(); err == nil {
+			t.Error("Expected error with", arg)
+		}
+	}
+}
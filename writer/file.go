@@ -0,0 +1,74 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a buffered file written to a temporary file next to its path and renamed over it on Close,
+// so a crash never leaves a half-written sitemap. Abort discards what was written
+type AtomicFile struct {
+	*bufio.Writer
+	path string
+	file *os.File
+}
+
+// CreateAtomicFile creates the temporary file of path, path itself is only replaced on Close
+func CreateAtomicFile(path string) (*AtomicFile, error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{Writer: bufio.NewWriter(file), path: path, file: file}, nil
+}
+
+// Close flushes the file to disk and renames it over its path
+func (self *AtomicFile) Close() error {
+	if err := self.Flush(); err != nil {
+		self.Abort()
+		return err
+	}
+	if err := self.file.Sync(); err != nil {
+		self.Abort()
+		return err
+	}
+	if err := self.file.Close(); err != nil {
+		os.Remove(self.file.Name())
+		return err
+	}
+	if err := os.Chmod(self.file.Name(), 0644); err != nil {
+		os.Remove(self.file.Name())
+		return err
+	}
+	return os.Rename(self.file.Name(), self.path)
+}
+
+// Abort removes the temporary file, leaving path untouched
+func (self *AtomicFile) Abort() error {
+	self.file.Close()
+	return os.Remove(self.file.Name())
+}
+
+// CreateFunc creates a file of a sitemap written in several files, ie CreateAtomicFile in the output directory
+type CreateFunc func(name string) (io.WriteCloser, error)
+
+// aborter is implemented by files like AtomicFile that can be discarded when writing them fails
+type aborter interface {
+	Abort() error
+}
+
+// closeOrAbort closes the file once written, or aborts it if writing failed and it can be aborted
+func closeOrAbort(file io.WriteCloser, err error) error {
+	if err != nil {
+		if abortable, ok := file.(aborter); ok {
+			abortable.Abort()
+		} else {
+			file.Close()
+		}
+		return err
+	}
+	return file.Close()
+}
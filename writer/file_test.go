@@ -0,0 +1,46 @@
+package writer_test
+
+import (
+	"github.com/terencechow/crawl/writer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sitemap.txt")
+	ioutil.WriteFile(path, []byte("previous\n"), 0644)
+
+	// the previous sitemap is kept until the file is closed
+	file, err := writer.CreateAtomicFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("https://example.com\n")
+	if content, _ := ioutil.ReadFile(path); string(content) != "previous\n" {
+		t.Error("Expected the previous sitemap before Close got", string(content))
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal("Expected no error got", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "https://example.com\n" {
+		t.Error("Expected the new sitemap after Close got", string(content))
+	}
+
+	// an aborted file leaves the sitemap untouched
+	file, _ = writer.CreateAtomicFile(path)
+	file.WriteString("half written")
+	file.Abort()
+	if content, _ := ioutil.ReadFile(path); string(content) != "https://example.com\n" {
+		t.Error("Expected the sitemap untouched by Abort got", string(content))
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Error("Expected the temporary files to be removed got", len(entries), "files")
+	}
+}
@@ -0,0 +1,253 @@
+package writer
+
+import (
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// GraphMode decides which links of the sitemap are drawn
+type GraphMode string
+
+const (
+	// GRAPH_TREE draws the spanning tree of the sitemap, each url under the page it was crawled from
+	GRAPH_TREE GraphMode = "tree"
+
+	// GRAPH_LINKS draws every link between pages, links back to urls crawled from other pages are dotted
+	GRAPH_LINKS GraphMode = "links"
+)
+
+// GraphOptions decide what the DOT and Mermaid graphs of a sitemap show
+type GraphOptions struct {
+	// Mode is GRAPH_TREE if empty
+	Mode GraphMode
+
+	// MaxDepth leaves out urls more than MaxDepth links away from the root. 0 means no limit
+	MaxDepth int
+
+	// Collapse are path prefixes, ie /blog/. Every url under a prefix is drawn as a single node
+	Collapse []string
+
+	// Incomplete is the reason the crawl did not finish, written as a comment at the top of the graph
+	Incomplete string
+}
+
+// node of a graph, a url or the urls under a collapsed prefix
+type graphNode struct {
+	id      string
+	label   string
+	skipped bool
+	urls    map[string]bool
+}
+
+// link of a graph, cross links are links to a url crawled from another page
+type graphEdge struct {
+	from, to int
+	cross    bool
+}
+
+// graph of a sitemap with its nodes & edges in the order they were found
+type graph struct {
+	nodes   []*graphNode
+	indexes map[string]int
+	edges   []*graphEdge
+	edgeSet map[[2]int]*graphEdge
+}
+
+// WriteDOT writes the sitemap as a Graphviz DOT digraph, render it with ie dot -Tsvg sitemap.dot
+func WriteDOT(w io.Writer, sitemap *crawler.Node, options GraphOptions) error {
+	g := newGraph(sitemap, options)
+	ew := &errWriter{w: w}
+	if options.Incomplete != "" {
+		ew.printf("// incomplete sitemap: %s\n", strings.Replace(options.Incomplete, "\n", " ", -1))
+	}
+	ew.printf("digraph sitemap {\n")
+	ew.printf("  rankdir=LR;\n")
+	ew.printf("  node [shape=box];\n")
+	for _, node := range g.nodes {
+		attrs := []string{}
+		if node.label != node.id {
+			attrs = append(attrs, "label="+dotQuote(node.label))
+		}
+		if node.skipped {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			ew.printf("  %s [%s];\n", dotQuote(node.id), strings.Join(attrs, ", "))
+		} else {
+			ew.printf("  %s;\n", dotQuote(node.id))
+		}
+	}
+	for _, edge := range g.edges {
+		style := ""
+		if edge.cross {
+			style = " [style=dotted]"
+		}
+		ew.printf("  %s -> %s%s;\n", dotQuote(g.nodes[edge.from].id), dotQuote(g.nodes[edge.to].id), style)
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+// WriteMermaid writes the sitemap as a Mermaid flowchart, ie to embed in markdown
+func WriteMermaid(w io.Writer, sitemap *crawler.Node, options GraphOptions) error {
+	g := newGraph(sitemap, options)
+	ew := &errWriter{w: w}
+	if options.Incomplete != "" {
+		ew.printf("%%%% incomplete sitemap: %s\n", strings.Replace(options.Incomplete, "\n", " ", -1))
+	}
+	ew.printf("flowchart LR\n")
+	hasSkipped := false
+	for i, node := range g.nodes {
+		class := ""
+		if node.skipped {
+			class = ":::skipped"
+			hasSkipped = true
+		}
+		ew.printf("  n%d[\"%s\"]%s\n", i, mermaidEscape(node.label), class)
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if edge.cross {
+			arrow = "-.->"
+		}
+		ew.printf("  n%d %s n%d\n", edge.from, arrow, edge.to)
+	}
+	if hasSkipped {
+		ew.printf("  classDef skipped stroke-dasharray: 5 5\n")
+	}
+	return ew.err
+}
+
+// newGraph returns the nodes & edges of the sitemap drawn with options
+func newGraph(sitemap *crawler.Node, options GraphOptions) *graph {
+	g := &graph{indexes: map[string]int{}, edgeSet: map[[2]int]*graphEdge{}}
+
+	// the synthetic root of several seeds is left out, each seed is a root
+	roots := []*crawler.Node{sitemap}
+	if sitemap.URL == crawler.SEEDS {
+		roots = []*crawler.Node{}
+		for _, seed := range sortedKeys(sitemap.Links) {
+			roots = append(roots, sitemap.Links[seed])
+		}
+	}
+	owners := treeOwners(roots)
+
+	var visit func(node *crawler.Node, depth int) int
+	visit = func(node *crawler.Node, depth int) int {
+		from := g.addNode(node, options.Collapse)
+		if options.MaxDepth > 0 && depth >= options.MaxDepth {
+			return from
+		}
+		for _, key := range sortedKeys(node.Links) {
+			link := node.Links[key]
+			cross := owners[link.URL] != link
+			if cross && options.Mode != GRAPH_LINKS {
+				continue
+			}
+
+			// only the node crawled for a url holds its links, others are leaves
+			var to int
+			if cross {
+				to = g.addNode(link, options.Collapse)
+			} else {
+				to = visit(link, depth+1)
+			}
+			g.addEdge(from, to, cross)
+		}
+		return from
+	}
+	for _, root := range roots {
+		visit(root, 0)
+	}
+	return g
+}
+
+// treeOwners returns the node of each url in the spanning tree of the sitemap.
+// That's the node that was crawled, or the shallowest one for urls that weren't
+func treeOwners(roots []*crawler.Node) map[string]*crawler.Node {
+	owners := map[string]*crawler.Node{}
+	queue := append([]*crawler.Node{}, roots...)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if owner, ok := owners[node.URL]; !ok || (!crawled(owner) && crawled(node)) {
+			owners[node.URL] = node
+		}
+		for _, key := range sortedKeys(node.Links) {
+			queue = append(queue, node.Links[key])
+		}
+	}
+	return owners
+}
+
+// crawled returns true for the node of a url that was fetched
+func crawled(node *crawler.Node) bool {
+	return node.Status != 0 || len(node.Links) > 0
+}
+
+// addNode adds the node of a url, or of the prefix it is collapsed under, and returns its index
+func (self *graph) addNode(node *crawler.Node, collapse []string) int {
+	id, collapsed := collapseURL(node.URL, collapse)
+	index, ok := self.indexes[id]
+	if !ok {
+		index = len(self.nodes)
+		self.indexes[id] = index
+		self.nodes = append(self.nodes, &graphNode{id: id, label: id, skipped: node.Skipped != "", urls: map[string]bool{}})
+	}
+
+	graphNode := self.nodes[index]
+	graphNode.urls[node.URL] = true
+	if node.Skipped == "" {
+		graphNode.skipped = false
+	}
+	if collapsed {
+		graphNode.skipped = false
+		graphNode.label = fmt.Sprintf("%s* (%d urls)", id, len(graphNode.urls))
+	}
+	return index
+}
+
+// addEdge adds a link between two nodes once, a link of the tree wins over a cross link
+func (self *graph) addEdge(from int, to int, cross bool) {
+	if from == to {
+		return
+	}
+	key := [2]int{from, to}
+	if edge, ok := self.edgeSet[key]; ok {
+		edge.cross = edge.cross && cross
+		return
+	}
+	edge := &graphEdge{from: from, to: to, cross: cross}
+	self.edgeSet[key] = edge
+	self.edges = append(self.edges, edge)
+}
+
+// collapseURL returns the url truncated to the first of the prefixes its path starts with, true if it was
+func collapseURL(rawURL string, prefixes []string) (string, bool) {
+	if len(prefixes) == 0 {
+		return rawURL, false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, false
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(parsed.Path, prefix) {
+			return parsed.Scheme + "://" + parsed.Host + prefix, true
+		}
+	}
+	return rawURL, false
+}
+
+// dotQuote returns a DOT quoted string
+func dotQuote(value string) string {
+	return `"` + strings.Replace(strings.Replace(value, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// mermaidEscape escapes the quotes of a Mermaid label
+func mermaidEscape(value string) string {
+	return strings.Replace(value, `"`, "#quot;", -1)
+}
@@ -0,0 +1,114 @@
+package writer_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"github.com/terencechow/crawl/writer"
+	"testing"
+)
+
+// graphSiteMap returns a sitemap where /about links back to the root and to /blog/a, crawled from /blog/
+func graphSiteMap() *Node {
+	rootURL := "https://example.com"
+	aboutURL, blogURL := rootURL+"/about", rootURL+"/blog/"
+	postA, postB, privateURL := rootURL+"/blog/a", rootURL+"/blog/b", rootURL+"/private"
+	return &Node{
+		URL:    rootURL,
+		Status: 200,
+		Links: map[string]*Node{
+			aboutURL: &Node{URL: aboutURL, Status: 200, Links: map[string]*Node{
+				rootURL: &Node{URL: rootURL, Links: map[string]*Node{}},
+				postA:   &Node{URL: postA, Links: map[string]*Node{}},
+			}},
+			blogURL: &Node{URL: blogURL, Status: 200, Links: map[string]*Node{
+				postA: &Node{URL: postA, Status: 200, Links: map[string]*Node{}},
+				postB: &Node{URL: postB, Status: 200, Links: map[string]*Node{}},
+			}},
+			privateURL: &Node{URL: privateURL, Links: map[string]*Node{}, Skipped: crawler.ROBOTS_DISALLOWED},
+		},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	expected := `digraph sitemap {
+  rankdir=LR;
+  node [shape=box];
+  "https://example.com";
+  "https://example.com/about";
+  "https://example.com/blog/";
+  "https://example.com/blog/a";
+  "https://example.com/blog/b";
+  "https://example.com/private" [style=dashed];
+  "https://example.com" -> "https://example.com/about";
+  "https://example.com/blog/" -> "https://example.com/blog/a";
+  "https://example.com/blog/" -> "https://example.com/blog/b";
+  "https://example.com" -> "https://example.com/blog/";
+  "https://example.com" -> "https://example.com/private";
+}
+`
+	var result bytes.Buffer
+	if err := writer.WriteDOT(&result, graphSiteMap(), writer.GraphOptions{}); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+
+	// the link graph adds the cross links, collapsing /blog/ into one node
+	expected = `// incomplete sitemap: crawl interrupted
+digraph sitemap {
+  rankdir=LR;
+  node [shape=box];
+  "https://example.com";
+  "https://example.com/about";
+  "https://example.com/blog/" [label="https://example.com/blog/* (3 urls)"];
+  "https://example.com/private" [style=dashed];
+  "https://example.com/about" -> "https://example.com" [style=dotted];
+  "https://example.com/about" -> "https://example.com/blog/" [style=dotted];
+  "https://example.com" -> "https://example.com/about";
+  "https://example.com" -> "https://example.com/blog/";
+  "https://example.com" -> "https://example.com/private";
+}
+`
+	options := writer.GraphOptions{Mode: writer.GRAPH_LINKS, Collapse: []string{"/blog/"}, Incomplete: "crawl interrupted"}
+	result.Reset()
+	if err := writer.WriteDOT(&result, graphSiteMap(), options); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	expected := `flowchart LR
+  n0["https://example.com"]
+  n1["https://example.com/about"]
+  n2["https://example.com/blog/"]
+  n3["https://example.com/private"]:::skipped
+  n0 --> n1
+  n0 --> n2
+  n0 --> n3
+  classDef skipped stroke-dasharray: 5 5
+`
+	var result bytes.Buffer
+	if err := writer.WriteMermaid(&result, graphSiteMap(), writer.GraphOptions{MaxDepth: 1}); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+
+	expected = `flowchart LR
+  n0["https://example.com"]
+  n1["https://example.com/about"]
+  n2["https://example.com/blog/a"]
+  n3["https://example.com/blog/"]
+  n4["https://example.com/blog/b"]
+  n5["https://example.com/private"]:::skipped
+  n1 -.-> n0
+  n1 -.-> n2
+  n0 --> n1
+  n3 --> n2
+  n3 --> n4
+  n0 --> n3
+  n0 --> n5
+  classDef skipped stroke-dasharray: 5 5
+`
+	result.Reset()
+	if err := writer.WriteMermaid(&result, graphSiteMap(), writer.GraphOptions{Mode: writer.GRAPH_LINKS}); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+}
@@ -0,0 +1,162 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/terencechow/crawl/crawler"
+	"io"
+	"strings"
+)
+
+// record of a crawled page written by JSONLinesWriter
+type jsonPage struct {
+	URL     string   `json:"url"`
+	Parent  string   `json:"parent,omitempty"`
+	Depth   int      `json:"depth"`
+	Status  int      `json:"status,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	NoIndex bool     `json:"noindex,omitempty"`
+	Links   []string `json:"links"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// last record written by JSONLinesWriter when a crawl did not finish
+type jsonIncomplete struct {
+	Incomplete string `json:"incomplete"`
+	Unfetched  int    `json:"unfetched"`
+}
+
+// WriteJSON streams the full Sitemap as an indented JSON document, node by node.
+// Every node has the url of its parent and its depth, links are sorted by url
+func WriteJSON(w io.Writer, sitemap *crawler.Node) error {
+	return writeJSON(w, sitemap, "")
+}
+
+// WriteIncompleteJSON is like WriteJSON for a crawl that did not finish, the reason is written in the incomplete field
+func WriteIncompleteJSON(w io.Writer, sitemap *crawler.Node, reason string) error {
+	return writeJSON(w, sitemap, reason)
+}
+
+func writeJSON(w io.Writer, sitemap *crawler.Node, incomplete string) error {
+	ew := &errWriter{w: w}
+	ew.printf("{\n")
+	if incomplete != "" {
+		ew.printf("  \"incomplete\": %s,\n", jsonString(incomplete))
+	}
+
+	// the synthetic root of several seeds is left out, each seed is a root
+	roots := []*crawler.Node{sitemap}
+	if sitemap.URL == crawler.SEEDS {
+		roots = []*crawler.Node{}
+		for _, seed := range sortedKeys(sitemap.Links) {
+			roots = append(roots, sitemap.Links[seed])
+		}
+	}
+	ew.printf("  \"roots\": ")
+	writeJSONNodes(ew, roots, "", 0, "  ")
+	ew.printf("\n}\n")
+	return ew.err
+}
+
+// writeJSONNodes writes an array of nodes and their links, indented by indent
+func writeJSONNodes(ew *errWriter, nodes []*crawler.Node, parent string, depth int, indent string) {
+	if len(nodes) == 0 {
+		ew.printf("[]")
+		return
+	}
+	ew.printf("[\n")
+	for i, node := range nodes {
+		if i > 0 {
+			ew.printf(",\n")
+		}
+		writeJSONNode(ew, node, parent, depth, indent+"  ")
+	}
+	ew.printf("\n%s]", indent)
+}
+
+// writeJSONNode writes a node along with the metadata of its page, its links are sorted by url
+func writeJSONNode(ew *errWriter, node *crawler.Node, parent string, depth int, indent string) {
+	field := indent + "  "
+	ew.printf("%s{\n", indent)
+	ew.printf("%s\"url\": %s,\n", field, jsonString(node.URL))
+	if parent != "" {
+		ew.printf("%s\"parent\": %s,\n", field, jsonString(parent))
+	}
+	ew.printf("%s\"depth\": %d,\n", field, depth)
+	if node.Status != 0 {
+		ew.printf("%s\"status\": %d,\n", field, node.Status)
+	}
+	if node.Source != "" {
+		ew.printf("%s\"source\": %s,\n", field, jsonString(node.Source))
+	}
+	if node.IsEmbed() {
+		ew.printf("%s\"embed\": true,\n", field)
+	}
+	if node.Skipped != "" {
+		ew.printf("%s\"skipped\": %s,\n", field, jsonString(node.Skipped))
+	}
+	if node.NoIndex {
+		ew.printf("%s\"noindex\": true,\n", field)
+	}
+
+	links := make([]*crawler.Node, 0, len(node.Links))
+	for _, key := range sortedKeys(node.Links) {
+		links = append(links, node.Links[key])
+	}
+	ew.printf("%s\"links\": ", field)
+	writeJSONNodes(ew, links, node.URL, depth+1, field)
+	ew.printf("\n%s}", indent)
+}
+
+// jsonString returns value as a JSON string, urls keep their & < > unescaped
+func jsonString(value string) string {
+	var result bytes.Buffer
+	encoder := json.NewEncoder(&result)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(result.String(), "\n")
+}
+
+// JSONLinesWriter writes a JSON record per line for every page as it is crawled.
+// Pass its WritePage to crawler.WithOnPage
+type JSONLinesWriter struct {
+	encoder *json.Encoder
+	err     error
+}
+
+// NewJSONLinesWriter returns a JSONLinesWriter writing to w
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &JSONLinesWriter{encoder: encoder}
+}
+
+// WritePage writes the record of a page, pages are dropped once a write failed
+func (self *JSONLinesWriter) WritePage(page crawler.Page) {
+	if self.err != nil {
+		return
+	}
+	self.err = self.encoder.Encode(jsonPage{
+		URL:     page.URL,
+		Parent:  page.Parent,
+		Depth:   page.Depth,
+		Status:  page.Status,
+		Source:  page.Source,
+		NoIndex: page.NoIndex,
+		Links:   page.Links,
+		Error:   page.Error,
+	})
+}
+
+// WriteIncomplete appends a last record for a crawl that did not finish, with the reason and the number of urls left unfetched
+func (self *JSONLinesWriter) WriteIncomplete(reason string, unfetched int) {
+	if self.err != nil {
+		return
+	}
+	self.err = self.encoder.Encode(jsonIncomplete{Incomplete: reason, Unfetched: unfetched})
+}
+
+// Err returns the first error writing a page
+func (self *JSONLinesWriter) Err() error {
+	return self.err
+}
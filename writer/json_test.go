@@ -0,0 +1,128 @@
+package writer_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"github.com/terencechow/crawl/writer"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	rootURL := "https://example.com"
+	aboutURL := rootURL + "/about?a=1&b=2"
+	sitemap := &Node{
+		URL:    rootURL,
+		Status: 200,
+		Links: map[string]*Node{
+			aboutURL: &Node{URL: aboutURL, Source: "a[href]", Status: 200, NoIndex: true, Links: map[string]*Node{
+				rootURL: &Node{URL: rootURL, Source: "a[href]", Links: map[string]*Node{}},
+			}},
+			rootURL + "/private": &Node{URL: rootURL + "/private", Source: "a[href]", Links: map[string]*Node{}, Skipped: crawler.ROBOTS_DISALLOWED},
+		},
+	}
+
+	expected := `{
+  "roots": [
+    {
+      "url": "https://example.com",
+      "depth": 0,
+      "status": 200,
+      "links": [
+        {
+          "url": "https://example.com/about?a=1&b=2",
+          "parent": "https://example.com",
+          "depth": 1,
+          "status": 200,
+          "source": "a[href]",
+          "noindex": true,
+          "links": [
+            {
+              "url": "https://example.com",
+              "parent": "https://example.com/about?a=1&b=2",
+              "depth": 2,
+              "source": "a[href]",
+              "links": []
+            }
+          ]
+        },
+        {
+          "url": "https://example.com/private",
+          "parent": "https://example.com",
+          "depth": 1,
+          "source": "a[href]",
+          "skipped": "disallowed by robots.txt",
+          "links": []
+        }
+      ]
+    }
+  ]
+}
+`
+	var result bytes.Buffer
+	if err := writer.WriteJSON(&result, sitemap); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+}
+
+func TestWriteIncompleteJSONSeeds(t *testing.T) {
+	sitemap := &Node{
+		URL: crawler.SEEDS,
+		Links: map[string]*Node{
+			"https://example.com/landing": &Node{URL: "https://example.com/landing", Links: map[string]*Node{}},
+			"https://example.com":         &Node{URL: "https://example.com", Links: map[string]*Node{}},
+		},
+	}
+
+	expected := `{
+  "incomplete": "crawl interrupted",
+  "roots": [
+    {
+      "url": "https://example.com",
+      "depth": 0,
+      "links": []
+    },
+    {
+      "url": "https://example.com/landing",
+      "depth": 0,
+      "links": []
+    }
+  ]
+}
+`
+	var result bytes.Buffer
+	if err := writer.WriteIncompleteJSON(&result, sitemap, "crawl interrupted"); err != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), err)
+	}
+}
+
+func TestJSONLinesWriter(t *testing.T) {
+	var result bytes.Buffer
+	pages := writer.NewJSONLinesWriter(&result)
+	pages.WritePage(crawler.Page{URL: "https://example.com", Status: 200, Links: []string{"https://example.com/about"}})
+	pages.WritePage(crawler.Page{URL: "https://example.com/about", Parent: "https://example.com", Depth: 1, Status: 404, Source: "a[href]", Links: []string{}, Error: "404 Not Found"})
+
+	expected := `{"url":"https://example.com","depth":0,"status":200,"links":["https://example.com/about"]}
+{"url":"https://example.com/about","parent":"https://example.com","depth":1,"status":404,"source":"a[href]","links":[],"error":"404 Not Found"}
+`
+	if pages.Err() != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), pages.Err())
+	}
+
+	// an incomplete crawl ends with a last record
+	result.Reset()
+	pages.WriteIncomplete("crawl interrupted", 3)
+	expected = `{"incomplete":"crawl interrupted","unfetched":3}
+`
+	if pages.Err() != nil || result.String() != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result.String()), pages.Err())
+	}
+
+	// pages are dropped once a write failed
+	pages = writer.NewJSONLinesWriter(&failingWriter{limit: 10})
+	pages.WritePage(crawler.Page{URL: "https://example.com"})
+	pages.WritePage(crawler.Page{URL: "https://example.com"})
+	if pages.Err() == nil {
+		t.Error("Expected the write error")
+	}
+}
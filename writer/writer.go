@@ -3,34 +3,110 @@ package writer
 import (
 	"fmt"
 	"github.com/terencechow/crawl/crawler"
+	"io"
 	"sort"
+	"strings"
 )
 
-// PrettifySiteMap prints the sitemap to a string using tabs for different depths
-func PrettifySiteMap(sitemap *crawler.Node, depth int) string {
-	result := ""
-	tabs := ""
-	for i := 0; i < depth; i++ {
-		tabs += "\t"
+// errWriter keeps the first error of a sequence of writes so they can be checked once
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (self *errWriter) printf(format string, args ...interface{}) {
+	if self.err == nil {
+		_, self.err = fmt.Fprintf(self.w, format, args...)
 	}
+}
+
+// WriteSiteMap streams the sitemap to w, one line per node using tabs for different depths.
+// The seeds of a sitemap crawled from several seeds are each written as their own root
+func WriteSiteMap(w io.Writer, sitemap *crawler.Node) error {
+	ew := &errWriter{w: w}
+	writeRoot(ew, sitemap)
+	return ew.err
+}
 
+// WriteIncompleteSiteMap is like WriteSiteMap for a crawl that did not finish.
+// The first line marks the sitemap as incomplete and states why
+func WriteIncompleteSiteMap(w io.Writer, sitemap *crawler.Node, reason string) error {
+	ew := &errWriter{w: w}
+	ew.printf("# incomplete sitemap: %s\n", reason)
+	writeRoot(ew, sitemap)
+	return ew.err
+}
+
+// PrettifySiteMap prints the sitemap to a string using tabs for different depths.
+// It holds the whole output in memory, use WriteSiteMap for large sitemaps
+func PrettifySiteMap(sitemap *crawler.Node, depth int) string {
+	var result strings.Builder
+	ew := &errWriter{w: &result}
 	if depth == 0 {
-		result += fmt.Sprintf("%s%s\n", tabs, sitemap.URL)
+		writeRoot(ew, sitemap)
+	} else {
+		writeLinks(ew, sitemap, depth)
+	}
+	return result.String()
+}
+
+// PrettifyIncompleteSiteMap is like PrettifySiteMap for a crawl that did not finish.
+// The first line marks the sitemap as incomplete and states why
+func PrettifyIncompleteSiteMap(sitemap *crawler.Node, reason string) string {
+	var result strings.Builder
+	WriteIncompleteSiteMap(&result, sitemap, reason)
+	return result.String()
+}
+
+// writeRoot writes the root line and the links of the sitemap, or of each seed of a sitemap crawled from several seeds
+func writeRoot(ew *errWriter, sitemap *crawler.Node) {
+	if sitemap.URL == crawler.SEEDS {
+		for _, k := range sortedKeys(sitemap.Links) {
+			writeRoot(ew, sitemap.Links[k])
+		}
+		return
 	}
+	ew.printf("%s%s\n", sitemap.URL, skippedSuffix(sitemap))
+	writeLinks(ew, sitemap, 0)
+}
 
-	// sort the links alphabetically
-	keys := make([]string, len(sitemap.Links))
+// writeLinks writes the links of a node at depth, sorted alphabetically, each followed by its own links
+func writeLinks(ew *errWriter, node *crawler.Node, depth int) {
+	tabs := strings.Repeat("\t", depth+1)
+	for _, k := range sortedKeys(node.Links) {
+		if ew.err != nil {
+			return
+		}
+		v := node.Links[k]
+		ew.printf("%s%s%s%s\n", tabs, k, embedSuffix(v), skippedSuffix(v))
+		writeLinks(ew, v, depth+1)
+	}
+}
+
+// sortedKeys returns the urls of links sorted alphabetically
+func sortedKeys(links map[string]*crawler.Node) []string {
+	keys := make([]string, len(links))
 	i := 0
-	for k := range sitemap.Links {
+	for k := range links {
 		keys[i] = k
 		i++
 	}
 	sort.Strings(keys)
+	return keys
+}
+
+// embedSuffix returns how a node was embedded in its parent page to tell embeds from navigation links
+func embedSuffix(node *crawler.Node) string {
+	if !node.IsEmbed() {
+		return ""
+	}
+	return fmt.Sprintf(" (embed: %s)", node.Source)
+}
 
-	for _, k := range keys {
-		v := sitemap.Links[k]
-		result += fmt.Sprintf("\t%s%s\n", tabs, k)
-		result += PrettifySiteMap(v, depth+1)
+// skippedSuffix returns the reason a node was not crawled to append to its line
+func skippedSuffix(node *crawler.Node) string {
+	if node.Skipped == "" {
+		return ""
 	}
-	return result
+	return fmt.Sprintf(" (skipped: %s)", node.Skipped)
 }
@@ -1,6 +1,8 @@
 package writer_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/terencechow/crawl/crawler"
 	"github.com/terencechow/crawl/writer"
@@ -58,3 +60,125 @@ func TestPrettifySiteMap(t *testing.T) {
 		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", expected, result))
 	}
 }
+
+func TestPrettifyIncompleteSiteMap(t *testing.T) {
+	rootURL := "https://example.com"
+	aboutURL := rootURL + "/about"
+	sitemap := &Node{
+		URL: rootURL,
+		Links: map[string]*Node{
+			aboutURL: &Node{URL: aboutURL, Links: map[string]*Node{}},
+		},
+	}
+
+	expected := "" +
+		"# incomplete sitemap: crawl interrupted\n" +
+		"https://example.com\n" +
+		"\thttps://example.com/about\n"
+
+	result := writer.PrettifyIncompleteSiteMap(sitemap, "crawl interrupted")
+	if result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", expected, result))
+	}
+}
+
+func TestPrettifySkippedNodes(t *testing.T) {
+	rootURL := "https://example.com"
+	privateURL := rootURL + "/private"
+	sitemap := &Node{
+		URL: rootURL,
+		Links: map[string]*Node{
+			privateURL: &Node{URL: privateURL, Links: map[string]*Node{}, Skipped: crawler.ROBOTS_DISALLOWED},
+		},
+	}
+
+	expected := "" +
+		"https://example.com\n" +
+		"\thttps://example.com/private (skipped: disallowed by robots.txt)\n"
+
+	result := writer.PrettifySiteMap(sitemap, 0)
+	if result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", expected, result))
+	}
+}
+
+func TestPrettifyEmbeddedNodes(t *testing.T) {
+	rootURL := "https://example.com"
+	frameURL := rootURL + "/frame"
+	nextURL := rootURL + "/next"
+	sitemap := &Node{
+		URL: rootURL,
+		Links: map[string]*Node{
+			frameURL: &Node{URL: frameURL, Links: map[string]*Node{}, Source: "iframe[src]"},
+			nextURL:  &Node{URL: nextURL, Links: map[string]*Node{}, Source: "link[href]"},
+		},
+	}
+
+	expected := "" +
+		"https://example.com\n" +
+		"\thttps://example.com/frame (embed: iframe[src])\n" +
+		"\thttps://example.com/next\n"
+
+	result := writer.PrettifySiteMap(sitemap, 0)
+	if result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", expected, result))
+	}
+}
+
+func TestPrettifySeeds(t *testing.T) {
+	homeURL := "https://example.com"
+	landingURL := homeURL + "/landing"
+	sitemap := &Node{
+		URL: crawler.SEEDS,
+		Links: map[string]*Node{
+			landingURL: &Node{URL: landingURL, Links: map[string]*Node{}},
+			homeURL: &Node{URL: homeURL, Links: map[string]*Node{
+				homeURL + "/about": &Node{URL: homeURL + "/about", Links: map[string]*Node{}, Source: "a[href]"},
+			}},
+		},
+	}
+
+	expected := "" +
+		"https://example.com\n" +
+		"\thttps://example.com/about\n" +
+		"https://example.com/landing\n"
+
+	result := writer.PrettifySiteMap(sitemap, 0)
+	if result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", expected, result))
+	}
+}
+
+// failingWriter fails every write after the first limit bytes
+type failingWriter struct {
+	limit int
+}
+
+func (self *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > self.limit {
+		written := self.limit
+		self.limit = 0
+		return written, errors.New("disk full")
+	}
+	self.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteSiteMap(t *testing.T) {
+	rootURL := "https://example.com"
+	sitemap := &Node{
+		URL: rootURL,
+		Links: map[string]*Node{
+			rootURL + "/about": &Node{URL: rootURL + "/about", Links: map[string]*Node{}},
+		},
+	}
+
+	var result bytes.Buffer
+	if err := writer.WriteSiteMap(&result, sitemap); err != nil || result.String() != writer.PrettifySiteMap(sitemap, 0) {
+		t.Error(fmt.Sprintf("Expected:\n%q\nGot:\n%q\n", writer.PrettifySiteMap(sitemap, 0), result.String()), err)
+	}
+
+	if err := writer.WriteSiteMap(&failingWriter{limit: 10}, sitemap); err == nil || err.Error() != "disk full" {
+		t.Error("Expected the write error got", err)
+	}
+}
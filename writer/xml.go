@@ -0,0 +1,260 @@
+package writer
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SITEMAP_NAMESPACE is the namespace of sitemaps.org 0.9 files
+const SITEMAP_NAMESPACE = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Limits of a single sitemap file of the sitemaps.org protocol, larger sitemaps are split and listed in a sitemap index
+const (
+	MAX_SITEMAP_URLS = 50000
+	MAX_SITEMAP_SIZE = 50 * 1024 * 1024
+)
+
+// XMLOptions are the optional tags added to every url of an XML sitemap
+type XMLOptions struct {
+	// LastMod is written as a W3C date, ie 2006-01-02. Omitted if zero
+	LastMod time.Time
+
+	// ChangeFreq is one of always, hourly, daily, weekly, monthly, yearly or never. Omitted if empty
+	ChangeFreq string
+
+	// Priority is between 0 and 1. Omitted if 0, search engines default to 0.5
+	Priority float64
+
+	// Incomplete is the reason the crawl did not finish, written as a comment after the XML declaration
+	Incomplete string
+}
+
+// IndexOptions decide how an XML sitemap too large for one file is split
+type IndexOptions struct {
+	// Name of the sitemap, ie sitemap.xml. Split files are numbered after it, ie sitemap-1.xml, and Name is the sitemap index
+	Name string
+
+	// BaseURL is the public url the files are served from, ie https://monzo.com/. Urls of the index are relative to it
+	BaseURL string
+
+	// Gzip compresses every file, adding .gz to their names
+	Gzip bool
+
+	// MaxURLs & MaxSize are the limits of a file, MAX_SITEMAP_URLS & MAX_SITEMAP_SIZE if 0
+	MaxURLs int
+	MaxSize int
+}
+
+// url element of the sitemaps.org protocol
+type xmlURL struct {
+	XMLName    xml.Name `xml:"url"`
+	Loc        string   `xml:"loc"`
+	LastMod    string   `xml:"lastmod,omitempty"`
+	ChangeFreq string   `xml:"changefreq,omitempty"`
+	Priority   string   `xml:"priority,omitempty"`
+}
+
+// sitemap element of sitemap indexes
+type xmlSitemap struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod,omitempty"`
+}
+
+// WriteXMLSiteMap streams the sitemap to w as a sitemaps.org urlset listing every unique url once, sorted alphabetically.
+// Only pages fetched with a 2xx response are listed, pages asking not to be indexed are left out
+func WriteXMLSiteMap(w io.Writer, sitemap *crawler.Node, options XMLOptions) error {
+	return writeURLSet(w, indexedURLs(sitemap), options)
+}
+
+// XMLSiteMap is like WriteXMLSiteMap returning the sitemap as a string
+func XMLSiteMap(sitemap *crawler.Node, options XMLOptions) (string, error) {
+	var result strings.Builder
+	err := WriteXMLSiteMap(&result, sitemap, options)
+	return result.String(), err
+}
+
+// IncompleteXMLSiteMap is like XMLSiteMap for a crawl that did not finish.
+// A comment after the XML declaration marks the sitemap as incomplete and states why
+func IncompleteXMLSiteMap(sitemap *crawler.Node, options XMLOptions, reason string) (string, error) {
+	options.Incomplete = reason
+	return XMLSiteMap(sitemap, options)
+}
+
+// WriteXMLSiteMapFiles writes the sitemap as a single file named after index.Name when it fits the limits of a file.
+// Larger sitemaps are split into numbered files and index.Name is a sitemapindex referencing them from index.BaseURL.
+// Every file is created with create
+func WriteXMLSiteMapFiles(create CreateFunc, sitemap *crawler.Node, options XMLOptions, index IndexOptions) error {
+	maxURLs, maxSize := index.MaxURLs, index.MaxSize
+	if maxURLs == 0 {
+		maxURLs = MAX_SITEMAP_URLS
+	}
+	if maxSize == 0 {
+		maxSize = MAX_SITEMAP_SIZE
+	}
+	chunks, err := splitURLs(indexedURLs(sitemap), options, maxURLs, maxSize)
+	if err != nil {
+		return err
+	}
+
+	suffix := ""
+	if index.Gzip {
+		suffix = ".gz"
+	}
+	if len(chunks) == 1 {
+		return writeFile(create, index.Name+suffix, index.Gzip, func(w io.Writer) error {
+			return writeURLSet(w, chunks[0], options)
+		})
+	}
+
+	sitemaps := []xmlSitemap{}
+	stem := strings.TrimSuffix(index.Name, ".xml")
+	for i, chunk := range chunks {
+		name := fmt.Sprintf("%s-%d.xml%s", stem, i+1, suffix)
+		err := writeFile(create, name, index.Gzip, func(w io.Writer) error {
+			return writeURLSet(w, chunk, options)
+		})
+		if err != nil {
+			return err
+		}
+
+		entry := xmlSitemap{Loc: strings.TrimSuffix(index.BaseURL, "/") + "/" + name}
+		if !options.LastMod.IsZero() {
+			entry.LastMod = options.LastMod.Format("2006-01-02")
+		}
+		sitemaps = append(sitemaps, entry)
+	}
+	return writeFile(create, index.Name+suffix, index.Gzip, func(w io.Writer) error {
+		return writeSitemapIndex(w, sitemaps, options.Incomplete)
+	})
+}
+
+// writeFile creates a file, gzipped if compress is set, and writes it with write
+func writeFile(create CreateFunc, name string, compress bool, write func(w io.Writer) error) error {
+	file, err := create(name)
+	if err != nil {
+		return err
+	}
+	if !compress {
+		return closeOrAbort(file, write(file))
+	}
+	gzipWriter := gzip.NewWriter(file)
+	err = write(gzipWriter)
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	return closeOrAbort(file, err)
+}
+
+// splitURLs splits the urls greedily into files within maxURLs & maxSize, without holding their url elements.
+// The urlset around them and the incomplete comment take up to 1KB
+func splitURLs(locs []string, options XMLOptions, maxURLs int, maxSize int) ([][]string, error) {
+	chunks := [][]string{}
+	start, size := 0, 1024
+	for i, loc := range locs {
+		entry, err := marshalURL(loc, options)
+		if err != nil {
+			return nil, err
+		}
+		if i > start && (i-start >= maxURLs || size+len(entry) > maxSize) {
+			chunks = append(chunks, locs[start:i])
+			start, size = i, 1024
+		}
+		size += len(entry)
+	}
+	return append(chunks, locs[start:]), nil
+}
+
+// writeURLSet writes a urlset of the urls, marshalling each url element straight into w
+func writeURLSet(w io.Writer, locs []string, options XMLOptions) error {
+	ew := &errWriter{w: w}
+	writeXMLHeader(ew, options.Incomplete)
+	ew.printf("<urlset xmlns=\"%s\">\n", SITEMAP_NAMESPACE)
+	for _, loc := range locs {
+		entry, err := marshalURL(loc, options)
+		if err != nil {
+			return err
+		}
+		ew.printf("%s", entry)
+	}
+	ew.printf("</urlset>\n")
+	return ew.err
+}
+
+// writeSitemapIndex writes a sitemapindex of sitemap elements
+func writeSitemapIndex(w io.Writer, sitemaps []xmlSitemap, incomplete string) error {
+	ew := &errWriter{w: w}
+	writeXMLHeader(ew, incomplete)
+	ew.printf("<sitemapindex xmlns=\"%s\">\n", SITEMAP_NAMESPACE)
+	for _, sitemap := range sitemaps {
+		data, err := xml.MarshalIndent(sitemap, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		ew.printf("%s\n", data)
+	}
+	ew.printf("</sitemapindex>\n")
+	return ew.err
+}
+
+// writeXMLHeader writes the XML declaration and a comment if the crawl was incomplete
+func writeXMLHeader(ew *errWriter, incomplete string) {
+	ew.printf("%s", xml.Header)
+	if incomplete != "" {
+		// comments can't contain --
+		ew.printf("<!-- incomplete sitemap: %s -->\n", strings.Replace(incomplete, "--", "- -", -1))
+	}
+}
+
+// marshalURL returns the indented url element of a url on its own lines
+func marshalURL(loc string, options XMLOptions) ([]byte, error) {
+	entry := xmlURL{Loc: loc, ChangeFreq: options.ChangeFreq}
+	if !options.LastMod.IsZero() {
+		entry.LastMod = options.LastMod.Format("2006-01-02")
+	}
+	if options.Priority != 0 {
+		entry.Priority = strconv.FormatFloat(options.Priority, 'f', -1, 64)
+	}
+	data, err := xml.MarshalIndent(entry, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// indexedURLs returns the sorted unique urls of the sitemap that belong in an XML sitemap, pages fetched with a 2xx response.
+// Only the node of a url that was crawled holds its Status & NoIndex, other nodes of the url are leaves
+func indexedURLs(sitemap *crawler.Node) []string {
+	indexed := map[string]bool{}
+	excluded := map[string]bool{}
+	var visit func(node *crawler.Node)
+	visit = func(node *crawler.Node) {
+		switch {
+		case node.URL == crawler.SEEDS:
+		case node.NoIndex || node.Skipped == crawler.ROBOTS_DISALLOWED:
+			excluded[node.URL] = true
+		case node.Status >= 200 && node.Status < 300:
+			indexed[node.URL] = true
+		}
+		for _, link := range node.Links {
+			visit(link)
+		}
+	}
+	visit(sitemap)
+
+	urls := make([]string, 0, len(indexed))
+	for loc := range indexed {
+		if !excluded[loc] {
+			urls = append(urls, loc)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,171 @@
+package writer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/terencechow/crawl/crawler"
+	"github.com/terencechow/crawl/writer"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXMLSiteMap(t *testing.T) {
+	rootURL := "https://example.com"
+	aboutURL := rootURL + "/about?a=1&b=2"
+	sitemap := &Node{
+		URL:    rootURL,
+		Status: 200,
+		Links: map[string]*Node{
+			aboutURL: &Node{URL: aboutURL, Status: 200, Links: map[string]*Node{
+				rootURL:             &Node{URL: rootURL, Links: map[string]*Node{}},
+				rootURL + "/deeper": &Node{URL: rootURL + "/deeper", Links: map[string]*Node{}},
+			}},
+			rootURL + "/private": &Node{URL: rootURL + "/private", Links: map[string]*Node{}, Skipped: crawler.ROBOTS_DISALLOWED},
+			rootURL + "/draft":   &Node{URL: rootURL + "/draft", Links: map[string]*Node{}, Status: 200, NoIndex: true},
+			rootURL + "/missing": &Node{URL: rootURL + "/missing", Links: map[string]*Node{}, Status: 404},
+			rootURL + "/error":   &Node{URL: rootURL + "/error", Links: map[string]*Node{}, Status: 500},
+			rootURL + "/gone":    &Node{URL: rootURL + "/gone", Links: map[string]*Node{}, Status: 301},
+		},
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com</loc>
+  </url>
+  <url>
+    <loc>https://example.com/about?a=1&amp;b=2</loc>
+  </url>
+</urlset>
+`
+	result, err := writer.XMLSiteMap(sitemap, writer.XMLOptions{})
+	if err != nil || result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result), err)
+	}
+
+	options := writer.XMLOptions{LastMod: time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC), ChangeFreq: "weekly", Priority: 0.8}
+	expected = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- incomplete sitemap: crawl interrupted -->
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com</loc>
+    <lastmod>2018-05-01</lastmod>
+    <changefreq>weekly</changefreq>
+    <priority>0.8</priority>
+  </url>
+</urlset>
+`
+	result, err = writer.IncompleteXMLSiteMap(&Node{URL: rootURL, Links: map[string]*Node{}, Status: 200}, options, "crawl interrupted")
+	if err != nil || result != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, result), err)
+	}
+
+	// priorities are written as given
+	result, _ = writer.XMLSiteMap(&Node{URL: rootURL, Links: map[string]*Node{}, Status: 200}, writer.XMLOptions{Priority: 0.25})
+	if !strings.Contains(result, "<priority>0.25</priority>") {
+		t.Error("Expected a priority of 0.25. Got", result)
+	}
+}
+
+// memoryFiles is a CreateFunc keeping the files in memory, in the order they were created
+type memoryFiles struct {
+	names    []string
+	contents map[string]*bytes.Buffer
+}
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (self memoryFile) Close() error {
+	return nil
+}
+
+func (self *memoryFiles) create(name string) (io.WriteCloser, error) {
+	if self.contents == nil {
+		self.contents = map[string]*bytes.Buffer{}
+	}
+	self.names = append(self.names, name)
+	self.contents[name] = &bytes.Buffer{}
+	return memoryFile{self.contents[name]}, nil
+}
+
+func TestWriteXMLSiteMapFiles(t *testing.T) {
+	rootURL := "https://example.com"
+	sitemap := &Node{URL: rootURL, Links: map[string]*Node{}, Status: 200}
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		sitemap.Links[rootURL+path] = &Node{URL: rootURL + path, Links: map[string]*Node{}, Status: 200}
+	}
+
+	// a sitemap within the limits is a single file
+	files := &memoryFiles{}
+	err := writer.WriteXMLSiteMapFiles(files.create, sitemap, writer.XMLOptions{}, writer.IndexOptions{Name: "sitemap.xml"})
+	single, _ := writer.XMLSiteMap(sitemap, writer.XMLOptions{})
+	if err != nil || !reflect.DeepEqual(files.names, []string{"sitemap.xml"}) || files.contents["sitemap.xml"].String() != single {
+		t.Error("Expected a single sitemap.xml", err)
+	}
+
+	files = &memoryFiles{}
+	index := writer.IndexOptions{Name: "sitemap.xml", BaseURL: "https://example.com/sitemaps/", MaxURLs: 2}
+	err = writer.WriteXMLSiteMapFiles(files.create, sitemap, writer.XMLOptions{}, index)
+	if err != nil {
+		t.Error("Expected no error got", err)
+	}
+	expectedNames := []string{"sitemap-1.xml", "sitemap-2.xml", "sitemap-3.xml", "sitemap.xml"}
+	if !reflect.DeepEqual(files.names, expectedNames) {
+		t.Error(fmt.Sprintf("Expected files %v. Got %v", expectedNames, files.names))
+	}
+	if last := files.contents["sitemap-3.xml"].String(); !strings.Contains(last, "<loc>https://example.com/d</loc>") {
+		t.Error("Expected the last url in the last file. Got", last)
+	}
+
+	expectedIndex := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://example.com/sitemaps/sitemap-1.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://example.com/sitemaps/sitemap-2.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://example.com/sitemaps/sitemap-3.xml</loc>
+  </sitemap>
+</sitemapindex>
+`
+	if result := files.contents["sitemap.xml"].String(); result != expectedIndex {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expectedIndex, result))
+	}
+
+	// files are also split by size
+	files = &memoryFiles{}
+	index = writer.IndexOptions{Name: "sitemap.xml", BaseURL: rootURL, MaxSize: 1200}
+	writer.WriteXMLSiteMapFiles(files.create, sitemap, writer.XMLOptions{}, index)
+	if len(files.names) < 3 {
+		t.Error("Expected the sitemap to be split by size. Got", len(files.names), "files")
+	}
+}
+
+func TestWriteXMLSiteMapFilesGzip(t *testing.T) {
+	sitemap := &Node{URL: "https://example.com", Links: map[string]*Node{}, Status: 200}
+	files := &memoryFiles{}
+	index := writer.IndexOptions{Name: "sitemap.xml", BaseURL: "https://example.com", Gzip: true}
+	err := writer.WriteXMLSiteMapFiles(files.create, sitemap, writer.XMLOptions{}, index)
+	if err != nil || !reflect.DeepEqual(files.names, []string{"sitemap.xml.gz"}) {
+		t.Fatal("Expected a single sitemap.xml.gz", err)
+	}
+
+	reader, err := gzip.NewReader(files.contents["sitemap.xml.gz"])
+	if err != nil {
+		t.Fatal("Expected gzip content got", err)
+	}
+	content, _ := ioutil.ReadAll(reader)
+	expected, _ := writer.XMLSiteMap(sitemap, writer.XMLOptions{})
+	if string(content) != expected {
+		t.Error(fmt.Sprintf("Expected:\n%s\nGot:\n%s\n", expected, content))
+	}
+}